@@ -1,12 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
 
 	"github.com/gooolib/errors"
 	"github.com/gooolib/migration/command"
-	"github.com/gooolib/migration/config"
-	"github.com/gooolib/migration/migrate"
 )
 
 func main() {
@@ -23,34 +24,12 @@ func main() {
 		}
 	}()
 
-	cfg := &config.Config{
-		Database: config.DBConfig{
-			Dialect:  "postgres",
-			Host:     "127.0.0.1",
-			Port:     5432,
-			Username: "postgres",
-			Password: "postgres",
-			Database: "gooolib_migration_development",
-			SSLMode:  "disable",
-		},
-		Command: config.NewCmdConfig(""),
-	}
-
-	m, err := migrate.NewMigration(cfg)
-	if err != nil {
-		log.Fatalf("Failed to create migration: %v", err)
-	}
-
-	if err := m.Load(cfg.Command.MigrationDir); err != nil {
-		log.Fatalf("Failed to load migrations: %v", err)
-	}
-
-	cmd, err := command.NewCommand(m)
-	if err != nil {
-		log.Fatalf("Failed to parse command: %v", err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	if err := cmd.Exec(); err != nil {
-		log.Fatalf("Command execution failed: %v", err)
+	if err := command.Run(ctx, os.Args[1:]); err != nil {
+		stop()
+		log.Printf("%v", err)
+		os.Exit(command.ExitCode(err))
 	}
 }
@@ -1,8 +1,10 @@
 package command
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 
 	"github.com/gooolib/migration/migrate"
 )
@@ -11,51 +13,127 @@ type Command struct {
 	Type      string
 	Executor  CommandExecutor
 	migration *migrate.Migration
+	out       io.Writer
 }
 
 type CommandExecutor interface {
 	Exec() error
+	// DefineFlags registers the command's flags before the arguments are
+	// parsed. It is also used to render help, without a migration, so it must
+	// not depend on one.
+	DefineFlags()
 	ParseArgs() error
 }
 
-const USAGE = "Usage: migrate <command> args...\nAvailable commands:\nup, down(rollback), rollback, reset, generate, status"
+// ExecutorFactory builds the executor of a subcommand. args is the flag set
+// of the subcommand and out is where the command writes its output.
+type ExecutorFactory func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor
 
-var availableCommands = map[string]func(*migrate.Migration, *flag.FlagSet) CommandExecutor{
-	"up": func(m *migrate.Migration, args *flag.FlagSet) CommandExecutor {
-		return &UpCommand{migration: m, args: args}
+// standaloneExecutor is implemented by commands that inspect the migrations
+// directory themselves and therefore run without loading it first, nor
+// connecting to the database.
+type standaloneExecutor interface {
+	Standalone() bool
+}
+
+// isStandalone reports whether the command named name runs without a
+// database connection.
+func isStandalone(name string) bool {
+	executor := availableCommands[name](nil, flag.NewFlagSet(name, flag.ContinueOnError), io.Discard)
+	standalone, ok := executor.(standaloneExecutor)
+	return ok && standalone.Standalone()
+}
+
+// schemaChanger is implemented by commands that may change the schema, after
+// which it is dumped when config.CmdConfig.DumpSchema is set.
+type schemaChanger interface {
+	ChangesSchema() bool
+}
+
+var availableCommands = map[string]ExecutorFactory{
+	"up": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &UpCommand{migration: m, args: args, out: out}
 	},
-	"down": func(m *migrate.Migration, args *flag.FlagSet) CommandExecutor {
+	"down": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
 		return &DownCommand{migration: m, args: args}
 	},
-	"rollback": func(m *migrate.Migration, args *flag.FlagSet) CommandExecutor {
+	"rollback": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
 		return &RollbackCommand{migration: m, args: args}
 	},
-	"generate": func(m *migrate.Migration, args *flag.FlagSet) CommandExecutor {
-		return &GenerateCommand{migration: m, args: args}
+	"generate": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &GenerateCommand{migration: m, args: args, out: out}
+	},
+	"status": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &StatusCommand{migration: m, args: args, out: out}
+	},
+	"validate": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &ValidateCommand{migration: m, args: args, out: out}
+	},
+	"reset": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &ResetCommand{migration: m, args: args}
 	},
-	"status": func(m *migrate.Migration, args *flag.FlagSet) CommandExecutor {
-		return &StatusCommand{migration: m, args: args}
+	"seed": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &SeedCommand{migration: m, args: args}
+	},
+	"dump": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &DumpCommand{migration: m, args: args, out: out}
+	},
+	"schema": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &SchemaCommand{migration: m, args: args}
+	},
+	"drift": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &DriftCommand{migration: m, args: args, out: out}
+	},
+	"squash": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &SquashCommand{migration: m, args: args, out: out}
+	},
+	"lint": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &LintCommand{migration: m, args: args, out: out}
+	},
+	"preflight": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &PreflightCommand{migration: m, args: args, out: out}
+	},
+	"verify": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &VerifyCommand{migration: m, args: args, out: out}
+	},
+	"help": func(m *migrate.Migration, args *flag.FlagSet, out io.Writer) CommandExecutor {
+		return &HelpCommand{args: args, out: out}
 	},
 }
 
-func NewCommand(m *migrate.Migration) (*Command, error) {
-	flag.Parse()
-	args := flag.Args()
+// Register adds a custom subcommand, typically from the main package of a
+// binary built around Run. It panics when the name is empty or already taken.
+func Register(name string, factory ExecutorFactory) {
+	if name == "" || factory == nil {
+		panic("command: Register requires a name and a factory")
+	}
+	if _, ok := availableCommands[name]; ok {
+		panic(fmt.Sprintf("command: Register called twice for command %s", name))
+	}
+	availableCommands[name] = factory
+}
+
+// NewCommand builds the subcommand named by args[0] and parses the remaining
+// arguments with its flags.
+func NewCommand(m *migrate.Migration, args []string, out io.Writer) (*Command, error) {
 	if len(args) < 1 {
-		return nil, fmt.Errorf(USAGE)
+		return nil, fmt.Errorf("no command given, run \"migrate help\" for the list of commands")
 	}
 	cmdType := args[0]
 	executorFactory, ok := availableCommands[cmdType]
 	if !ok {
-		return nil, fmt.Errorf("unknown command: %s\n%s", cmdType, USAGE)
+		return nil, fmt.Errorf("unknown command: %s, run \"migrate help\" for the list of commands", cmdType)
 	}
 
-	argsFlagSet := flag.NewFlagSet(cmdType, flag.ExitOnError)
+	argsFlagSet := flag.NewFlagSet(cmdType, flag.ContinueOnError)
+	argsFlagSet.SetOutput(out)
+	executor := executorFactory(m, argsFlagSet, out)
+	executor.DefineFlags()
+	argsFlagSet.Usage = func() { writeCommandUsage(out, cmdType, argsFlagSet, executor) }
 	if err := argsFlagSet.Parse(args[1:]); err != nil {
 		return nil, err
 	}
 
-	executor := executorFactory(m, argsFlagSet)
 	err := executor.ParseArgs()
 	if err != nil {
 		return nil, err
@@ -65,17 +143,69 @@ func NewCommand(m *migrate.Migration) (*Command, error) {
 		Type:      cmdType,
 		Executor:  executor,
 		migration: m,
+		out:       out,
 	}, nil
 }
 
 func (c *Command) Exec() error {
-	version := c.migration.GetCurrentVersion()
-	if version == "" {
-		version = "initial"
+	fmt.Fprintln(c.out, "")
+	fmt.Fprintln(c.out, "Command:", c.Type)
+	if standalone, ok := c.Executor.(standaloneExecutor); !ok || !standalone.Standalone() {
+		if err := c.migration.Load(c.migration.Config().Command.MigrationDir); err != nil {
+			return fmt.Errorf("failed to load migrations: %w", err)
+		}
+
+		version := c.migration.GetCurrentVersion()
+		if version == "" {
+			version = "initial"
+		}
+		fmt.Fprintln(c.out, "Current version:", version)
 	}
-	fmt.Println("")
-	fmt.Println("Command:", c.Type)
-	fmt.Println("Current version:", version)
 
-	return c.Executor.Exec()
+	err := c.Executor.Exec()
+	var migrationErr *migrate.MigrationError
+	if errors.As(err, &migrationErr) {
+		printMigrationError(c.out, migrationErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if changer, ok := c.Executor.(schemaChanger); ok && changer.ChangesSchema() && c.migration.Config().Command.DumpSchema {
+		if err := c.migration.DumpSchema(""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printMigrationError(w io.Writer, err *migrate.MigrationError) {
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "Migration %s failed: %s\n", err.Version, err.Message)
+	fmt.Fprintf(w, "  --> %s:%d:%d (statement %d)\n", err.File, err.Line, err.Column, err.StatementIndex)
+	fmt.Fprint(w, err.Snippet())
+	if err.Code != "" {
+		fmt.Fprintf(w, "  sqlstate: %s\n", err.Code)
+	}
+	if err.Detail != "" {
+		fmt.Fprintf(w, "  detail: %s\n", err.Detail)
+	}
+	if err.Hint != "" {
+		fmt.Fprintf(w, "  hint: %s\n", err.Hint)
+	}
+	if err.Where != "" {
+		fmt.Fprintf(w, "  where: %s\n", err.Where)
+	}
+	fmt.Fprintln(w, "")
+}
+
+// isFlagSet reports whether the flag was given on the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
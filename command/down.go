@@ -2,7 +2,6 @@ package command
 
 import (
 	"flag"
-	"fmt"
 
 	"github.com/gooolib/migration/migrate"
 )
@@ -20,9 +19,9 @@ func (c *DownCommand) Exec() error {
 	}
 
 	if c.Version != "" {
-		file := c.migration.FindFileByVersion(c.Version, "down")
-		if file == nil {
-			return fmt.Errorf("migration file with version %s not found", c.Version)
+		file, err := c.migration.FindDownFile(c.Version)
+		if err != nil {
+			return err
 		}
 		return c.migration.RunSingleDown(*file)
 	}
@@ -30,14 +29,23 @@ func (c *DownCommand) Exec() error {
 	return c.migration.Down()
 }
 
+func (c *DownCommand) Synopsis() string {
+	return "Revert the latest migration, a given --version or --all of them"
+}
+
+func (c *DownCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *DownCommand) DefineFlags() {
+	c.args.StringVar(&c.Version, "version", "", "migrate to specific version")
+	c.args.BoolVar(&c.DownAll, "all", false, "revert every applied migration")
+}
+
 func (c *DownCommand) ParseArgs() error {
-	version := c.args.String("version", "", "migrate to specific version")
-	if version != nil {
-		c.Version = *version
-	}
-	downAll := c.args.Lookup("all")
-	if downAll != nil {
-		c.DownAll = true
-	}
 	return nil
 }
+
+func (c *DownCommand) ChangesSchema() bool {
+	return true
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type DriftCommand struct {
+	args      *flag.FlagSet
+	migration *migrate.Migration
+	out       io.Writer
+}
+
+func (c *DriftCommand) Synopsis() string {
+	return "Compare the database schema with the one its applied migrations produce"
+}
+
+func (c *DriftCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *DriftCommand) DefineFlags() {}
+
+func (c *DriftCommand) ParseArgs() error {
+	return nil
+}
+
+func (c *DriftCommand) Exec() error {
+	changes, err := c.migration.Drift()
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintln(c.out, "No drift: the database matches its migrations")
+		return nil
+	}
+
+	fmt.Fprintln(c.out, "- expected by the migrations but missing, + only in the database, ~ different")
+	for _, change := range changes {
+		fmt.Fprintln(c.out, change.String())
+	}
+	return withExitCode(ExitDrift, fmt.Errorf("%d difference(s) between the database and its migrations", len(changes)))
+}
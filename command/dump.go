@@ -0,0 +1,45 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type DumpCommand struct {
+	Output    string
+	args      *flag.FlagSet
+	migration *migrate.Migration
+	out       io.Writer
+}
+
+func (c *DumpCommand) Synopsis() string {
+	return "Write the database schema as SQL to the schema file"
+}
+
+func (c *DumpCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *DumpCommand) DefineFlags() {
+	c.args.StringVar(&c.Output, "output", "", "file to write instead of the configured schema file, - for standard output")
+}
+
+func (c *DumpCommand) ParseArgs() error {
+	return nil
+}
+
+func (c *DumpCommand) Exec() error {
+	if c.Output != "-" {
+		return c.migration.DumpSchema(c.Output)
+	}
+
+	schema, err := c.migration.InspectSchema()
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	fmt.Fprint(c.out, schema.SQL())
+	return nil
+}
@@ -0,0 +1,73 @@
+package command
+
+import (
+	"errors"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+// Exit codes returned by ExitCode, one per failure class.
+const (
+	ExitOK         = 0
+	ExitFailure    = 1
+	ExitUsage      = 2
+	ExitConfig     = 3
+	ExitConnection = 4
+	ExitValidation = 5
+	ExitMigration  = 6
+	ExitDrift      = 7
+	ExitLint       = 8
+	ExitPreflight  = 9
+	ExitVerify     = 10
+)
+
+// ExitError attaches an exit code to an error. Custom commands can return it
+// to control the exit status of the binary.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// ExitCode returns the process exit code for an error returned by Run.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	var problems migrate.ValidationErrors
+	if errors.As(err, &problems) {
+		return ExitValidation
+	}
+	var migrationErr *migrate.MigrationError
+	if errors.As(err, &migrationErr) {
+		return ExitMigration
+	}
+	var preflightErr *migrate.PreflightError
+	if errors.As(err, &preflightErr) {
+		return ExitPreflight
+	}
+	var irreversibleErr *migrate.IrreversibleMigrationError
+	if errors.As(err, &irreversibleErr) {
+		return ExitMigration
+	}
+	return ExitFailure
+}
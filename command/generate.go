@@ -3,9 +3,7 @@ package command
 import (
 	"flag"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"io"
 	"time"
 
 	"github.com/gooolib/migration/migrate"
@@ -14,47 +12,52 @@ import (
 type GenerateCommand struct {
 	migration *migrate.Migration
 	args      *flag.FlagSet
+	out       io.Writer
 	Name      string
+	Template  string
+	Diff      string
 }
 
 func (c *GenerateCommand) Exec() error {
-	timestamp := time.Now().Format("20060102150405")
-	upfileName := fmt.Sprintf("%s_%s.up.sql", timestamp, c.Name)
-	downfileName := fmt.Sprintf("%s_%s.down.sql", timestamp, c.Name)
-
-	config := c.migration.Config()
-	migrationDir := config.Command.MigrationDir
-	files := []string{upfileName, downfileName}
-	filePaths := make([]string, len(files))
-	for i, fileName := range files {
-		filePath := filepath.Join(migrationDir, fileName)
-		filePaths[i] = filePath
-
-		file, err := os.Create(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to create migration file: %w", err)
-		}
-		defer file.Close()
-		template := fmt.Sprintf("-- Migration\n-- Created at: %s\n\n-- Write your SQL here\n", time.Now().Format("2006-01-02 15:04:05"))
-		if _, err := file.WriteString(template); err != nil {
-			return fmt.Errorf("failed to write migration template: %w", err)
-		}
+	var filePaths []string
+	var err error
+	if c.Diff != "" {
+		filePaths, err = c.migration.GenerateDiff(c.Name, c.Diff, time.Now())
+	} else {
+		filePaths, err = c.migration.Generate(c.Name, c.Template, time.Now())
+	}
+	if err != nil {
+		return err
 	}
 
-	if len(filePaths) > 0 {
-		log.Printf("Migration files generated successfully:")
-		for _, filePath := range filePaths {
-			log.Printf("file: %s", filePath)
-		}
+	fmt.Fprintln(c.out, "Migration files generated successfully:")
+	for _, filePath := range filePaths {
+		fmt.Fprintf(c.out, "file: %s\n", filePath)
 	}
 	return nil
 }
 
+func (c *GenerateCommand) Synopsis() string {
+	return "Create a new pair of up and down migration files"
+}
+
+func (c *GenerateCommand) ArgsUsage() string {
+	return "<name>"
+}
+
+func (c *GenerateCommand) DefineFlags() {
+	c.args.StringVar(&c.Template, "template", "", "template kind of the new files: table, index, data or one from the template directory")
+	c.args.StringVar(&c.Diff, "diff", "", "generate the SQL from the differences with a desired-state SQL file, or db for the connected database")
+}
+
 func (c *GenerateCommand) ParseArgs() error {
 	c.Name = c.args.Arg(0)
 	if c.Name == "" {
 		return fmt.Errorf("migration name is required")
 	}
+	if c.Diff != "" && c.Template != "" {
+		return fmt.Errorf("--diff and --template can't be combined")
+	}
 
 	return nil
 }
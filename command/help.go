@@ -0,0 +1,141 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Describer is implemented by executors that document themselves in the
+// output of "migrate help".
+type Describer interface {
+	// Synopsis is a one-line description of the command.
+	Synopsis() string
+	// ArgsUsage lists the positional arguments, e.g. "<name>", or "".
+	ArgsUsage() string
+}
+
+type HelpCommand struct {
+	args *flag.FlagSet
+	out  io.Writer
+}
+
+func (c *HelpCommand) DefineFlags() {}
+
+func (c *HelpCommand) ParseArgs() error {
+	return nil
+}
+
+func (c *HelpCommand) Synopsis() string {
+	return "Show the list of commands or the usage of one command"
+}
+
+func (c *HelpCommand) ArgsUsage() string {
+	return "[command]"
+}
+
+func (c *HelpCommand) Exec() error {
+	name := c.args.Arg(0)
+	if name == "" {
+		printUsage(c.out, nil)
+		return nil
+	}
+	return printCommandUsage(c.out, name)
+}
+
+// printUsage writes the global usage with the list of commands. globals is
+// the global flag set, omitted when nil.
+func printUsage(w io.Writer, globals *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: migrate [global flags] <command> [flags] [args]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(availableCommands))
+	for name := range availableCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		synopsis := ""
+		if describer, ok := newHelpExecutor(name, io.Discard).(Describer); ok {
+			synopsis = describer.Synopsis()
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", name, synopsis)
+	}
+	tw.Flush()
+
+	if globals == nil {
+		globals = newGlobalFlagSet(io.Discard, &globalOptions{})
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Global flags:")
+	globals.SetOutput(w)
+	globals.PrintDefaults()
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, `Run "migrate help <command>" for the usage of a command.`)
+}
+
+func printCommandUsage(w io.Writer, name string) error {
+	if _, ok := availableCommands[name]; !ok {
+		return withExitCode(ExitUsage, fmt.Errorf("unknown command: %s", name))
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	executor := availableCommands[name](nil, fs, io.Discard)
+	executor.DefineFlags()
+	writeCommandUsage(w, name, fs, executor)
+	return nil
+}
+
+func writeCommandUsage(w io.Writer, name string, fs *flag.FlagSet, executor CommandExecutor) {
+	synopsis, argsUsage := "", ""
+	if describer, ok := executor.(Describer); ok {
+		synopsis, argsUsage = describer.Synopsis(), describer.ArgsUsage()
+	}
+
+	line := []string{"Usage: migrate [global flags]", name}
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		line = append(line, "[flags]")
+	}
+	if argsUsage != "" {
+		line = append(line, argsUsage)
+	}
+
+	fmt.Fprintln(w, strings.Join(line, " "))
+	if synopsis != "" {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, synopsis)
+	}
+	if hasFlags {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Flags:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+// newHelpExecutor builds an executor only to read its documentation.
+func newHelpExecutor(name string, out io.Writer) CommandExecutor {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	return availableCommands[name](nil, fs, out)
+}
+
+// wantsHelp reports whether the subcommand arguments ask for its usage.
+func wantsHelp(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "-help", "--help", "--h":
+			return true
+		}
+	}
+	return false
+}
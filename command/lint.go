@@ -0,0 +1,218 @@
+package command
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type LintCommand struct {
+	All       bool
+	Format    string
+	args      *flag.FlagSet
+	migration *migrate.Migration
+	out       io.Writer
+}
+
+func (c *LintCommand) Synopsis() string {
+	return "Flag statements of the pending migrations that lock tables for long"
+}
+
+func (c *LintCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *LintCommand) DefineFlags() {
+	c.args.BoolVar(&c.All, "all", false, "lint every migration instead of the pending ones")
+	c.args.StringVar(&c.Format, "format", "text", "output format: text, json or sarif")
+}
+
+func (c *LintCommand) ParseArgs() error {
+	switch c.Format {
+	case "text", "json", "sarif":
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, expected text, json or sarif", c.Format)
+	}
+}
+
+func (c *LintCommand) Exec() error {
+	files := c.migration.UpFiles
+	if !c.All {
+		var err error
+		if files, err = c.migration.PendingFiles(); err != nil {
+			return err
+		}
+	}
+
+	findings, err := c.migration.Lint(files)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "json":
+		err = writeLintJSON(c.out, findings)
+	case "sarif":
+		err = writeLintSARIF(c.out, findings)
+	default:
+		writeLintText(c.out, findings, len(files))
+	}
+	if err != nil {
+		return err
+	}
+
+	failures := 0
+	for _, f := range findings {
+		if f.Rule.Severity == migrate.SeverityError {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return withExitCode(ExitLint, fmt.Errorf("%d lint error(s) found", failures))
+	}
+	return nil
+}
+
+func writeLintText(w io.Writer, findings []migrate.LintFinding, files int) {
+	for _, f := range findings {
+		fmt.Fprintln(w, f.String())
+	}
+	if len(findings) == 0 {
+		fmt.Fprintf(w, "No problems found in %d file(s)\n", files)
+		return
+	}
+	fmt.Fprintln(w, "")
+	for _, rule := range migrate.LintRules {
+		for _, f := range findings {
+			if f.Rule.ID == rule.ID {
+				fmt.Fprintf(w, "%s: %s\n", rule.ID, rule.Description)
+				break
+			}
+		}
+	}
+	fmt.Fprintln(w, "Suppress a finding with \"-- migrate:lint-ignore <rule>\" before the statement.")
+}
+
+type lintJSONFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Message  string `json:"message"`
+}
+
+func writeLintJSON(w io.Writer, findings []migrate.LintFinding) error {
+	out := make([]lintJSONFinding, len(findings))
+	for i, f := range findings {
+		out[i] = lintJSONFinding{
+			Rule:     f.Rule.ID,
+			Severity: f.Rule.Severity,
+			File:     f.File,
+			Line:     f.Line,
+			Column:   f.Column,
+			Message:  f.Message,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// The subset of SARIF 2.1.0 code scanning tools read.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+func writeLintSARIF(w io.Writer, findings []migrate.LintFinding) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "migrate"}},
+		Results: []sarifResult{},
+	}
+	for _, rule := range migrate.LintRules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfiguration{Level: rule.Severity},
+		})
+	}
+	for _, f := range findings {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  f.Rule.ID,
+			Level:   f.Rule.Severity,
+			Message: sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.File)},
+				Region:           sarifRegion{StartLine: f.Line, StartColumn: f.Column},
+			}}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
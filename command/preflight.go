@@ -0,0 +1,59 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type PreflightCommand struct {
+	args      *flag.FlagSet
+	migration *migrate.Migration
+	out       io.Writer
+
+	wait   time.Duration
+	maxAge time.Duration
+}
+
+func (c *PreflightCommand) Synopsis() string {
+	return "List sessions that would block the pending migrations"
+}
+
+func (c *PreflightCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *PreflightCommand) DefineFlags() {
+	c.args.DurationVar(&c.wait, "wait", 0, "wait up to this long for blocking sessions to finish")
+	c.args.DurationVar(&c.maxAge, "max-age", 0, "age from which an open transaction blocks (default cmd.preflight_transaction_age, or 1m)")
+}
+
+func (c *PreflightCommand) ParseArgs() error {
+	return nil
+}
+
+func (c *PreflightCommand) Exec() error {
+	var opts []migrate.PreflightOption
+	if c.maxAge > 0 {
+		opts = append(opts, migrate.WithPreflightTransactionAge(c.maxAge))
+	}
+
+	err := c.migration.Preflight(c.wait, opts...)
+	var preflightErr *migrate.PreflightError
+	if errors.As(err, &preflightErr) {
+		for _, s := range preflightErr.Sessions {
+			fmt.Fprintln(c.out, s.String())
+		}
+		return withExitCode(ExitPreflight, fmt.Errorf("%d session(s) would block the migrations", len(preflightErr.Sessions)))
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "No blocking sessions")
+	return nil
+}
@@ -7,7 +7,7 @@ import (
 )
 
 type ResetCommand struct {
-	args      flag.FlagSet
+	args      *flag.FlagSet
 	migration *migrate.Migration
 
 	hard bool
@@ -21,10 +21,22 @@ func (c *ResetCommand) Exec() error {
 	return c.migration.SoftReset()
 }
 
+func (c *ResetCommand) Synopsis() string {
+	return "Revert and reapply every migration, or drop every table with --hard"
+}
+
+func (c *ResetCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *ResetCommand) DefineFlags() {
+	c.args.BoolVar(&c.hard, "hard", false, "drop every table instead of running down migrations")
+}
+
 func (c *ResetCommand) ParseArgs() error {
-	hard := c.args.Lookup("hard")
-	if hard != nil {
-		c.hard = true
-	}
 	return nil
 }
+
+func (c *ResetCommand) ChangesSchema() bool {
+	return true
+}
@@ -7,14 +7,38 @@ import (
 )
 
 type RollbackCommand struct {
+	Batch     bool
 	args      *flag.FlagSet
 	migration *migrate.Migration
 }
 
 func (c *RollbackCommand) Exec() error {
+	if c.Batch {
+		return c.migration.RollbackBatch()
+	}
+
 	return c.migration.Down()
 }
 
+func (c *RollbackCommand) Synopsis() string {
+	return "Revert the latest migration, or the whole latest batch with --batch"
+}
+
+func (c *RollbackCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *RollbackCommand) DefineFlags() {
+	c.args.BoolVar(&c.Batch, "batch", false, "roll back every migration from the most recent batch (default from cmd.rollback_by_batch)")
+}
+
 func (c *RollbackCommand) ParseArgs() error {
+	if !isFlagSet(c.args, "batch") {
+		c.Batch = c.migration.Config().Command.RollbackByBatch
+	}
 	return nil
 }
+
+func (c *RollbackCommand) ChangesSchema() bool {
+	return true
+}
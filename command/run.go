@@ -0,0 +1,232 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gooolib/migration/config"
+	"github.com/gooolib/migration/migrate"
+)
+
+type runOptions struct {
+	config    *config.Config
+	migration *migrate.Migration
+	out       io.Writer
+}
+
+// Option customizes Run.
+type Option func(*runOptions)
+
+// WithConfig sets the configuration used to connect to the database and
+// locate the migrations. It defaults to config.Default(). The --config
+// global flag takes precedence over it.
+func WithConfig(cfg *config.Config) Option {
+	return func(o *runOptions) {
+		o.config = cfg
+	}
+}
+
+// WithMigration runs the command against an existing migration instead of
+// creating one from the configuration. Run doesn't close it, and rejects the
+// global flags that would configure it, such as --dsn.
+func WithMigration(m *migrate.Migration) Option {
+	return func(o *runOptions) {
+		o.migration = m
+	}
+}
+
+// WithOutput sets where commands and migration logs write to. It defaults to
+// os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(o *runOptions) {
+		o.out = w
+	}
+}
+
+// globalOptions are the flags accepted before the subcommand.
+type globalOptions struct {
+	configPath string
+	env        string
+	dir        string
+	dsn        string
+	table      string
+	vars       varsFlag
+}
+
+// varsFlag collects repeated --var name=value flags.
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v varsFlag) Set(pair string) error {
+	name, value, ok := strings.Cut(pair, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", pair)
+	}
+	v[name] = value
+	return nil
+}
+
+func newGlobalFlagSet(out io.Writer, g *globalOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&g.configPath, "config", "", "path to a YAML config file with one section per environment")
+	fs.StringVar(&g.env, "env", "", "environment section of the config file (default $MIGRATE_ENV or development)")
+	fs.StringVar(&g.dir, "dir", "", "directory containing the migration files")
+	fs.StringVar(&g.dsn, "dsn", "", "database connection string, overrides the configured database")
+	g.vars = varsFlag{}
+	fs.Var(g.vars, "var", "template variable as name=value, repeatable")
+	fs.StringVar(&g.table, "table", "", "name of the table recording applied migrations, optionally schema-qualified as schema.table")
+	return fs
+}
+
+// Run executes the command line args, e.g. Run(ctx, os.Args[1:]). Global
+// flags come first, followed by the subcommand and its own flags. It is the
+// entry point of cmd/migrate and of custom binaries that register their own
+// Go migrations or subcommands. Use ExitCode to turn the returned error into
+// a process exit status.
+func Run(ctx context.Context, args []string, opts ...Option) error {
+	o := &runOptions{out: os.Stdout}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	g := &globalOptions{}
+	globals := newGlobalFlagSet(o.out, g)
+	globals.Usage = func() { printUsage(o.out, globals) }
+	if err := globals.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return withExitCode(ExitUsage, err)
+	}
+
+	args = globals.Args()
+	if len(args) < 1 {
+		printUsage(o.out, globals)
+		return withExitCode(ExitUsage, fmt.Errorf("no command given"))
+	}
+	name := args[0]
+	if _, ok := availableCommands[name]; !ok {
+		return withExitCode(ExitUsage, fmt.Errorf("unknown command: %s, run \"migrate help\" for the list of commands", name))
+	}
+	if name == "help" {
+		if len(args) > 1 {
+			return printCommandUsage(o.out, args[1])
+		}
+		printUsage(o.out, globals)
+		return nil
+	}
+	if wantsHelp(args[1:]) {
+		return printCommandUsage(o.out, name)
+	}
+
+	m := o.migration
+	if m != nil {
+		// The caller configured the migration, so the flags configuring one
+		// can't apply to it.
+		if set := configFlagsSet(globals); len(set) > 0 {
+			return withExitCode(ExitUsage, fmt.Errorf("%s can't be used with a migration passed to Run", strings.Join(set, ", ")))
+		}
+	} else {
+		cfg, err := resolveConfig(o.config, g)
+		if err != nil {
+			return withExitCode(ExitConfig, err)
+		}
+		if isStandalone(name) {
+			m = migrate.NewOfflineMigration(cfg)
+		} else {
+			m, err = migrate.NewMigration(cfg)
+			if err != nil {
+				return withExitCode(ExitConnection, fmt.Errorf("failed to create migration: %w", err))
+			}
+			defer m.Close()
+		}
+	}
+	m.SetContext(ctx)
+	m.SetVars(g.vars)
+	m.SetLogger(log.New(o.out, "", log.LstdFlags))
+
+	cmd, err := NewCommand(m, args, o.out)
+	if err != nil {
+		return withExitCode(ExitUsage, fmt.Errorf("failed to parse command: %w", err))
+	}
+
+	if err := cmd.Exec(); err != nil {
+		return fmt.Errorf("command execution failed: %w", err)
+	}
+	return nil
+}
+
+// configFlagsSet returns the global flags given on the command line that
+// configure the migration, as "--name".
+func configFlagsSet(globals *flag.FlagSet) []string {
+	var set []string
+	for _, name := range []string{"config", "env", "dir", "dsn", "table"} {
+		if isFlagSet(globals, name) {
+			set = append(set, "--"+name)
+		}
+	}
+	return set
+}
+
+// resolveConfig picks the configuration from the --config file, the
+// WithConfig option or the default, in that order, and applies the other
+// global flags on top of it.
+func resolveConfig(base *config.Config, g *globalOptions) (*config.Config, error) {
+	env := g.env
+	if env == "" {
+		env = os.Getenv("MIGRATE_ENV")
+	}
+
+	var cfg *config.Config
+	switch {
+	case g.configPath != "":
+		loaded, err := config.Load(g.configPath, env)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	case base != nil:
+		copied := *base
+		cfg = &copied
+	default:
+		cfg = config.Default()
+	}
+
+	if env != "" {
+		cfg.Env = env
+	}
+	if cfg.Env == "" {
+		cfg.Env = config.DefaultEnv
+	}
+	if g.dir != "" {
+		cfg.Command.MigrationDir = g.dir
+	}
+	if g.dsn != "" {
+		cfg.Database.URL = g.dsn
+	}
+	if g.table != "" {
+		if schema, table, ok := strings.Cut(g.table, "."); ok {
+			cfg.Command.TableSchema = schema
+			cfg.Command.Table = table
+		} else {
+			cfg.Command.Table = g.table
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type SchemaCommand struct {
+	Action    string
+	File      string
+	Force     bool
+	args      *flag.FlagSet
+	migration *migrate.Migration
+}
+
+func (c *SchemaCommand) Synopsis() string {
+	return "Load the schema file into an empty database and record the versions it covers"
+}
+
+func (c *SchemaCommand) ArgsUsage() string {
+	return "load"
+}
+
+func (c *SchemaCommand) DefineFlags() {
+	c.args.StringVar(&c.File, "file", "", "schema file to load instead of the configured one")
+	c.args.BoolVar(&c.Force, "force", false, "load even if the database is not empty")
+}
+
+func (c *SchemaCommand) ParseArgs() error {
+	c.Action = c.args.Arg(0)
+	if c.Action != "load" {
+		return fmt.Errorf("unknown schema action %q, expected load", c.Action)
+	}
+	// Flags may also follow the action: "schema load --force".
+	return c.args.Parse(c.args.Args()[1:])
+}
+
+func (c *SchemaCommand) Exec() error {
+	return c.migration.LoadSchema(c.File, c.Force)
+}
@@ -0,0 +1,35 @@
+package command
+
+import (
+	"flag"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type SeedCommand struct {
+	Reset     bool
+	Force     bool
+	args      *flag.FlagSet
+	migration *migrate.Migration
+}
+
+func (c *SeedCommand) Synopsis() string {
+	return "Run the seed files that haven't run yet in the current environment"
+}
+
+func (c *SeedCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *SeedCommand) DefineFlags() {
+	c.args.BoolVar(&c.Reset, "reset", false, "forget previous runs and run every seed again (development and test only)")
+	c.args.BoolVar(&c.Force, "force", false, "allow --reset outside the development and test environments")
+}
+
+func (c *SeedCommand) ParseArgs() error {
+	return nil
+}
+
+func (c *SeedCommand) Exec() error {
+	return c.migration.Seed(c.Reset, c.Force)
+}
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type SquashCommand struct {
+	Before    string
+	Force     bool
+	args      *flag.FlagSet
+	migration *migrate.Migration
+	out       io.Writer
+}
+
+func (c *SquashCommand) Synopsis() string {
+	return "Replace the migrations up to --before with one baseline migration"
+}
+
+func (c *SquashCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *SquashCommand) DefineFlags() {
+	c.args.StringVar(&c.Before, "before", "", "squash the migrations at or below this version")
+	c.args.BoolVar(&c.Force, "force", false, "squash even migrations using template variables or changing data")
+}
+
+func (c *SquashCommand) ParseArgs() error {
+	if c.Before == "" {
+		return fmt.Errorf("--before is required")
+	}
+	return nil
+}
+
+func (c *SquashCommand) Exec() error {
+	path, err := c.migration.Squash(c.Before, c.Force)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Baseline migration written: %s\n", path)
+	return nil
+}
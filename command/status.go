@@ -3,7 +3,7 @@ package command
 import (
 	"flag"
 	"fmt"
-	"os"
+	"io"
 	"text/tabwriter"
 
 	"github.com/gooolib/migration/migrate"
@@ -12,8 +12,19 @@ import (
 type StatusCommand struct {
 	args      *flag.FlagSet
 	migration *migrate.Migration
+	out       io.Writer
 }
 
+func (c *StatusCommand) Synopsis() string {
+	return "List migrations with their applied status"
+}
+
+func (c *StatusCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *StatusCommand) DefineFlags() {}
+
 func (c *StatusCommand) ParseArgs() error {
 	return nil
 }
@@ -24,16 +35,20 @@ func (c *StatusCommand) Exec() error {
 		return err
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
 
 	fmt.Fprintln(w, "")
 	fmt.Fprintln(w, "")
-	fmt.Fprintf(w, "|\tVersion\t|\tStatus\t|\n")
-	fmt.Fprintln(w, "+\t=================\t+\t========\t+")
+	fmt.Fprintf(w, "|\tVersion\t|\tName\t|\tSource\t|\tStatus\t|\tBatch\t|\n")
+	fmt.Fprintln(w, "+\t=================\t+\t====\t+\t======\t+\t========\t+\t=====\t+")
 	for _, status := range statuses {
-		fmt.Fprintln(w, fmt.Sprintf("|\t%s\t|\t%s\t|", status.Version, status.Status))
+		batch := ""
+		if status.Batch > 0 {
+			batch = fmt.Sprintf("%d", status.Batch)
+		}
+		fmt.Fprintln(w, fmt.Sprintf("|\t%s\t|\t%s\t|\t%s\t|\t%s\t|\t%s\t|", status.Version, status.Name, status.Source, status.Status, batch))
 	}
-	fmt.Fprintln(w, "+\t=================\t+\t========\t+")
+	fmt.Fprintln(w, "+\t=================\t+\t====\t+\t======\t+\t========\t+\t=====\t+")
 	fmt.Fprintln(w, "")
 	fmt.Fprintln(w, "")
 	w.Flush()
@@ -1,34 +1,118 @@
 package command
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/gooolib/migration/migrate"
 )
 
 type UpCommand struct {
-	Version   string
-	args      *flag.FlagSet
-	migration *migrate.Migration
+	Version string
+	DryRun  bool
+	// Test runs the pending migrations and rolls them back.
+	Test bool
+	// Preflight and PreflightWait override the preflight settings of the
+	// configuration.
+	Preflight     bool
+	PreflightWait time.Duration
+	args          *flag.FlagSet
+	migration     *migrate.Migration
+	out           io.Writer
+}
+
+func (c *UpCommand) Synopsis() string {
+	return "Apply every pending migration, or a single one with --version"
+}
+
+func (c *UpCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *UpCommand) DefineFlags() {
+	c.args.StringVar(&c.Version, "version", "", "migrate to specific version")
+	c.args.BoolVar(&c.DryRun, "dry-run", false, "print the rendered SQL of the pending migrations without running them")
+	c.args.BoolVar(&c.Test, "test", false, "run the pending migrations, then roll them back instead of committing")
+	c.args.BoolVar(&c.Preflight, "preflight", false, "abort if sessions would block the migrations")
+	c.args.DurationVar(&c.PreflightWait, "preflight-wait", 0, "wait up to this long for blocking sessions to finish (implies --preflight)")
 }
 
 func (c *UpCommand) ParseArgs() error {
-	version := c.args.String("version", "", "migrate to specific version")
-	if version != nil {
-		c.Version = *version
+	if c.Test && (c.DryRun || c.Version != "") {
+		return errors.New("--test can't be combined with --dry-run or --version")
 	}
 	return nil
 }
 
 func (c *UpCommand) Exec() error {
+	if c.DryRun {
+		return c.dryRun()
+	}
+
+	var opts []migrate.PreflightOption
+	if c.Preflight || c.PreflightWait > 0 {
+		opts = append(opts, migrate.WithPreflight(c.PreflightWait))
+	}
+
+	if c.Test {
+		if err := c.migration.TestUp(opts...); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "Test run succeeded, every change was rolled back")
+		return nil
+	}
+
 	if c.Version != "" {
 		file := c.migration.FindFileByVersion(c.Version, "up")
 		if file == nil {
 			return fmt.Errorf("migration file with version %s not found", c.Version)
 		}
-		return c.migration.RunSingleUp(*file)
+		return c.migration.RunSingleUp(*file, opts...)
 	}
 
-	return c.migration.Up()
+	return c.migration.Up(opts...)
+}
+
+func (c *UpCommand) dryRun() error {
+	files, err := c.migration.PendingFiles()
+	if err != nil {
+		return err
+	}
+	if c.Version != "" {
+		file := c.migration.FindFileByVersion(c.Version, "up")
+		if file == nil {
+			return fmt.Errorf("migration file with version %s not found", c.Version)
+		}
+		files = []migrate.MigrationFile{*file}
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintln(c.out, "No pending migrations")
+		return nil
+	}
+
+	for _, file := range files {
+		fmt.Fprintf(c.out, "-- %s\n", file.Path)
+		if file.IsGo() {
+			fmt.Fprintln(c.out, "-- (Go migration)")
+			fmt.Fprintln(c.out, "")
+			continue
+		}
+		sql, err := c.migration.Render(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, sql)
+		fmt.Fprintln(c.out, "")
+	}
+	return nil
+}
+
+// ChangesSchema is false for --dry-run, which only prints SQL, and for
+// --test, which rolls back.
+func (c *UpCommand) ChangesSchema() bool {
+	return !c.DryRun && !c.Test
 }
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type ValidateCommand struct {
+	args      *flag.FlagSet
+	migration *migrate.Migration
+	out       io.Writer
+}
+
+func (c *ValidateCommand) Synopsis() string {
+	return "Check the names and pairing of the migration files"
+}
+
+func (c *ValidateCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *ValidateCommand) DefineFlags() {}
+
+func (c *ValidateCommand) ParseArgs() error {
+	return nil
+}
+
+// Standalone lets validate run against a directory that fails to load, and
+// offline, e.g. in CI without a database.
+func (c *ValidateCommand) Standalone() bool {
+	return true
+}
+
+func (c *ValidateCommand) Exec() error {
+	dir := c.migration.Config().Command.MigrationDir
+	problems, err := migrate.Validate(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		fmt.Fprintf(c.out, "All migration files in %s are valid\n", dir)
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintln(c.out, problem.Error())
+	}
+	return withExitCode(ExitValidation, fmt.Errorf("%d problem(s) found in %s", len(problems), dir))
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gooolib/migration/migrate"
+)
+
+type VerifyCommand struct {
+	args      *flag.FlagSet
+	migration *migrate.Migration
+	out       io.Writer
+}
+
+func (c *VerifyCommand) Synopsis() string {
+	return "Check that the down file of every pending migration reverts it, on a scratch database"
+}
+
+func (c *VerifyCommand) ArgsUsage() string {
+	return ""
+}
+
+func (c *VerifyCommand) DefineFlags() {}
+
+func (c *VerifyCommand) ParseArgs() error {
+	return nil
+}
+
+func (c *VerifyCommand) Exec() error {
+	problems, err := c.migration.Verify()
+	if err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		fmt.Fprintln(c.out, "Every pending migration reverts cleanly")
+		return nil
+	}
+
+	fmt.Fprintln(c.out, "- in the expected schema but missing, + left behind, ~ different")
+	for _, problem := range problems {
+		fmt.Fprintln(c.out, problem.String())
+	}
+	return withExitCode(ExitVerify, fmt.Errorf("%d problem(s) found in the round trips of the pending migrations", len(problems)))
+}
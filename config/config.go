@@ -1,14 +1,149 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnv is the environment used when none is given.
+const DefaultEnv = "development"
 
 type Config struct {
 	Database DBConfig  `yaml:"db" json:"db"`
 	Command  CmdConfig `yaml:"cmd" json:"cmd"`
+	// Env is the environment the configuration was loaded for.
+	Env string `yaml:"-" json:"-"`
+}
+
+// Default returns the configuration for the local development database.
+func Default() *Config {
+	return &Config{
+		Database: DBConfig{
+			Dialect:  "postgres",
+			Host:     "127.0.0.1",
+			Port:     5432,
+			Username: "postgres",
+			Password: "postgres",
+			Database: "gooolib_migration_development",
+			SSLMode:  "disable",
+		},
+		Command: NewCmdConfig(""),
+		Env:     DefaultEnv,
+	}
+}
+
+// Load reads the section for env from a YAML file keyed by environment:
+//
+//	development:
+//	  db:
+//	    dialect: postgres
+//	    url: ${DATABASE_URL}
+//	  cmd:
+//	    migration_dir: ./db/migrations
+//
+// Environment variables referenced as $VAR or ${VAR} are expanded first.
+func Load(path string, env string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var envs map[string]Config
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(content))), &envs); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if env == "" {
+		env = DefaultEnv
+	}
+	cfg, ok := envs[env]
+	if !ok {
+		return nil, fmt.Errorf("environment %q not found in config file %s", env, path)
+	}
+
+	defaults := NewCmdConfig("")
+	if cfg.Command.MigrationDir == "" {
+		cfg.Command.MigrationDir = defaults.MigrationDir
+	}
+	if cfg.Command.Table == "" {
+		cfg.Command.Table = defaults.Table
+	}
+	if cfg.Command.SeedDir == "" {
+		cfg.Command.SeedDir = defaults.SeedDir
+	}
+	if cfg.Command.SeedTable == "" {
+		cfg.Command.SeedTable = defaults.SeedTable
+	}
+	cfg.Env = env
+
+	return &cfg, nil
 }
 
 type CmdConfig struct {
 	MigrationDir string `yaml:"migration_dir" json:"migration_dir"`
+	// RollbackByBatch makes the rollback command revert the whole last batch by default.
+	RollbackByBatch bool `yaml:"rollback_by_batch" json:"rollback_by_batch"`
+	// Table is the name of the table recording applied migrations.
+	Table string `yaml:"table" json:"table"`
+	// TableSchema is the schema of Table, created when missing. Empty means
+	// the table is resolved through the search_path.
+	TableSchema string `yaml:"table_schema" json:"table_schema"`
+	// SeedDir is the directory containing the seed files.
+	SeedDir string `yaml:"seed_dir" json:"seed_dir"`
+	// SeedTable is the name of the table recording which seeds ran, in TableSchema.
+	SeedTable string `yaml:"seed_table" json:"seed_table"`
+	// SeedResetTables are emptied by seed --reset before the seeds run again,
+	// so that seeds inserting rows don't collide with the rows of their
+	// previous run. Seeds filling other tables must be idempotent.
+	SeedResetTables []string `yaml:"seed_reset_tables" json:"seed_reset_tables"`
+	// SharedDir holds the fragments inlined by "-- migrate:include". It
+	// defaults to "shared" next to MigrationDir.
+	SharedDir string `yaml:"shared_dir" json:"shared_dir"`
+	// TemplateDir holds custom templates for generate, as <kind>.up.sql and
+	// <kind>.down.sql. It defaults to "templates" next to MigrationDir.
+	TemplateDir string `yaml:"template_dir" json:"template_dir"`
+	// VersionScheme numbers generated migrations: "timestamp" (local time,
+	// the default), "utc" or "sequential".
+	VersionScheme string `yaml:"version_scheme" json:"version_scheme"`
+	// SchemaFile is where dump writes the schema. It defaults to "schema.sql"
+	// next to MigrationDir.
+	SchemaFile string `yaml:"schema_file" json:"schema_file"`
+	// DumpSchema dumps the schema to SchemaFile after every up, down and rollback.
+	DumpSchema bool `yaml:"dump_schema" json:"dump_schema"`
+	// LockTimeout and StatementTimeout are set for each migration, in the
+	// Postgres format ("5s", "1min"): with SET LOCAL inside the transaction
+	// of up, down and rollback and of Go migrations, and with SET on a
+	// connection of its own, reset afterwards, for the single SQL file run by
+	// up and down --version. The
+	// "-- migrate:lock-timeout" and "-- migrate:statement-timeout" directives
+	// override them for one file. Empty leaves the server setting.
+	LockTimeout      string `yaml:"lock_timeout" json:"lock_timeout"`
+	StatementTimeout string `yaml:"statement_timeout" json:"statement_timeout"`
+	// LockRetries is how many times a migration failing on lock_timeout is
+	// retried before giving up.
+	LockRetries int `yaml:"lock_retries" json:"lock_retries"`
+	// LockRetryBackoff is the wait before the first retry, doubled after
+	// each one. It defaults to one second.
+	LockRetryBackoff time.Duration `yaml:"lock_retry_backoff" json:"lock_retry_backoff"`
+	// Preflight makes up check first for sessions that would block its DDL:
+	// transactions open for longer than PreflightTransactionAge (default one
+	// minute) and sessions holding locks on the tables the pending migrations
+	// touch. It waits up to PreflightWait for them to finish, then aborts.
+	Preflight               bool          `yaml:"preflight" json:"preflight"`
+	PreflightTransactionAge time.Duration `yaml:"preflight_transaction_age" json:"preflight_transaction_age"`
+	PreflightWait           time.Duration `yaml:"preflight_wait" json:"preflight_wait"`
+	// Template enables ${name} substitution in every migration file. Files
+	// can opt in one by one with the "-- migrate:template" directive instead.
+	Template bool `yaml:"template" json:"template"`
+	// Vars are template variables. MIGRATE_VAR_<name> environment variables
+	// and --var flags override them.
+	Vars map[string]string `yaml:"vars" json:"vars"`
 }
 
 func NewCmdConfig(migrationDir string) CmdConfig {
@@ -18,12 +153,18 @@ func NewCmdConfig(migrationDir string) CmdConfig {
 	}
 	return CmdConfig{
 		MigrationDir: dirPath,
+		Table:        "schema_migrations",
+		SeedDir:      "./db/seeds",
+		SeedTable:    "schema_seeds",
 	}
 }
 
 type DBConfig struct {
 	// currently support only "postgres"
-	Dialect  string `yaml:"dialect" json:"dialect"`
+	Dialect string `yaml:"dialect" json:"dialect"`
+	// URL is a complete connection string. When set it takes precedence over
+	// the individual connection fields below.
+	URL      string `yaml:"url" json:"url"`
 	Host     string `yaml:"host" json:"host"`
 	Port     int    `yaml:"port" json:"port"`
 	Username string `yaml:"username" json:"username"`
@@ -33,8 +174,34 @@ type DBConfig struct {
 }
 
 func (c *DBConfig) DSN() string {
+	if c.URL != "" {
+		return c.URL
+	}
 	if c.Dialect == "postgres" {
 		return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s", c.Username, c.Password, c.Host, c.Port, c.Database, c.SSLMode)
 	}
 	return ""
 }
+
+var dbnamePattern = regexp.MustCompile(`(^|\s)dbname=('(?:[^'\\]|\\.)*'|\S*)`)
+
+// WithDatabase returns a copy of c connecting to the database name on the
+// same server, with the same credentials.
+func (c DBConfig) WithDatabase(name string) DBConfig {
+	c.Database = name
+	if c.URL == "" {
+		return c
+	}
+
+	if u, err := url.Parse(c.URL); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		u.Path = "/" + name
+		u.RawPath = ""
+		c.URL = u.String()
+		return c
+	}
+
+	// A key=value connection string.
+	value := "dbname='" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name) + "'"
+	c.URL = strings.TrimSpace(dbnamePattern.ReplaceAllLiteralString(c.URL, "") + " " + value)
+	return c
+}
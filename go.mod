@@ -6,10 +6,10 @@ require (
 	github.com/gooolib/errors v0.0.0-20250314205124-0769f4da9980
 	github.com/lib/pq v1.10.9
 	github.com/stretchr/testify v1.11.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
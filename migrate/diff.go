@@ -0,0 +1,298 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of change reported by DiffSchemas.
+const (
+	// ChangeMissing is an object of the expected schema absent from the actual one.
+	ChangeMissing = "missing"
+	// ChangeExtra is an object of the actual schema absent from the expected one.
+	ChangeExtra = "extra"
+	// ChangeChanged is an object defined differently in both schemas.
+	ChangeChanged = "changed"
+)
+
+// SchemaChange is one difference between two schemas.
+type SchemaChange struct {
+	Change string
+	// Kind is the kind of object: "schema", "extension", "enum", "domain",
+	// "type", "sequence", "function", "table", "column", "constraint", "index",
+	// "trigger" or "view".
+	Kind string
+	// Table is the table owning a column, constraint, index or trigger.
+	Table string
+	Name  string
+	// Attribute is the property of a changed column that differs: "type",
+	// "not null", "default", "identity" or "generated", or "partitioning" for
+	// a table. It is empty when the definition is compared as a whole.
+	Attribute string
+	// Expected and Actual are the definitions on each side, empty for the
+	// side the object is absent from.
+	Expected string
+	Actual   string
+}
+
+func (c SchemaChange) String() string {
+	name := c.Name
+	if c.Table != "" {
+		name = c.Table + "." + c.Name
+	}
+	switch c.Change {
+	case ChangeMissing:
+		return fmt.Sprintf("- %s %s is missing: %s", c.Kind, name, oneLine(c.Expected))
+	case ChangeExtra:
+		return fmt.Sprintf("+ %s %s is not in the migrations: %s", c.Kind, name, oneLine(c.Actual))
+	}
+	if c.Attribute != "" {
+		return fmt.Sprintf("~ %s %s %s differs: expected %q, actual %q", c.Kind, name, c.Attribute, c.Expected, c.Actual)
+	}
+	return fmt.Sprintf("~ %s %s differs:\n    expected: %s\n    actual:   %s", c.Kind, name, oneLine(c.Expected), oneLine(c.Actual))
+}
+
+// oneLine collapses the whitespace of a definition for display.
+func oneLine(definition string) string {
+	return strings.Join(strings.Fields(definition), " ")
+}
+
+// DiffSchemas returns the differences between the expected schema, e.g. the
+// one the migrations produce, and the actual one, sorted by kind of object
+// and name. Applied versions are not compared.
+func DiffSchemas(expected *Schema, actual *Schema) []SchemaChange {
+	d := &schemaDiff{}
+
+	d.objects("schema", "", setOf(expected.Schemas), setOf(actual.Schemas))
+	d.objects("extension", "", extensionDefinitions(expected), extensionDefinitions(actual))
+	d.objects("enum", "", enumDefinitions(expected), enumDefinitions(actual))
+	d.objects("domain", "", domainDefinitions(expected), domainDefinitions(actual))
+	d.objects("type", "", typeDefinitions(expected), typeDefinitions(actual))
+	d.objects("sequence", "", sequenceDefinitions(expected), sequenceDefinitions(actual))
+	d.objects("function", "", functionDefinitions(expected), functionDefinitions(actual))
+	d.tables(expected.Tables, actual.Tables)
+	d.objects("view", "", viewDefinitions(expected), viewDefinitions(actual))
+
+	return d.changes
+}
+
+type schemaDiff struct {
+	changes []SchemaChange
+}
+
+// objects compares two sets of definitions keyed by name.
+func (d *schemaDiff) objects(kind string, table string, expected, actual map[string]string) {
+	for _, name := range unionKeys(expected, actual) {
+		e, inExpected := expected[name]
+		a, inActual := actual[name]
+		change := SchemaChange{Kind: kind, Table: table, Name: name, Expected: e, Actual: a}
+		switch {
+		case !inActual:
+			change.Change = ChangeMissing
+		case !inExpected:
+			change.Change = ChangeExtra
+		case e != a:
+			change.Change = ChangeChanged
+		default:
+			continue
+		}
+		d.changes = append(d.changes, change)
+	}
+}
+
+func (d *schemaDiff) tables(expected, actual []Table) {
+	expectedTables := tablesByName(expected)
+	actualTables := tablesByName(actual)
+	names := unionKeys(expectedTables, actualTables)
+
+	for _, name := range names {
+		e, inExpected := expectedTables[name]
+		a, inActual := actualTables[name]
+		switch {
+		case !inActual:
+			d.changes = append(d.changes, SchemaChange{Change: ChangeMissing, Kind: "table", Name: name, Expected: e.SQL()})
+		case !inExpected:
+			d.changes = append(d.changes, SchemaChange{Change: ChangeExtra, Kind: "table", Name: name, Actual: a.SQL()})
+		default:
+			if e.Partitioning() != a.Partitioning() {
+				d.changes = append(d.changes, SchemaChange{
+					Change:    ChangeChanged,
+					Kind:      "table",
+					Name:      name,
+					Attribute: "partitioning",
+					Expected:  e.Partitioning(),
+					Actual:    a.Partitioning(),
+				})
+			}
+			d.columns(name, e.Columns, a.Columns)
+			d.objects("constraint", name, constraintDefinitions(e), constraintDefinitions(a))
+			d.objects("index", name, indexDefinitions(e), indexDefinitions(a))
+			d.objects("trigger", name, triggerDefinitions(e), triggerDefinitions(a))
+		}
+	}
+}
+
+// columns compares the columns of a table attribute by attribute. Column
+// order is not compared.
+func (d *schemaDiff) columns(table string, expected, actual []Column) {
+	expectedColumns := map[string]Column{}
+	for _, c := range expected {
+		expectedColumns[c.Name] = c
+	}
+	actualColumns := map[string]Column{}
+	for _, c := range actual {
+		actualColumns[c.Name] = c
+	}
+
+	for _, name := range unionKeys(expectedColumns, actualColumns) {
+		e, inExpected := expectedColumns[name]
+		a, inActual := actualColumns[name]
+		switch {
+		case !inActual:
+			d.changes = append(d.changes, SchemaChange{Change: ChangeMissing, Kind: "column", Table: table, Name: name, Expected: e.SQL()})
+			continue
+		case !inExpected:
+			d.changes = append(d.changes, SchemaChange{Change: ChangeExtra, Kind: "column", Table: table, Name: name, Actual: a.SQL()})
+			continue
+		}
+
+		attributes := []struct {
+			name             string
+			expected, actual string
+		}{
+			{"type", e.Type, a.Type},
+			{"not null", fmt.Sprint(e.NotNull), fmt.Sprint(a.NotNull)},
+			{"default", e.Default, a.Default},
+			{"identity", e.Identity, a.Identity},
+			{"generated", e.Generated, a.Generated},
+		}
+		for _, attr := range attributes {
+			if attr.expected != attr.actual {
+				d.changes = append(d.changes, SchemaChange{
+					Change:    ChangeChanged,
+					Kind:      "column",
+					Table:     table,
+					Name:      name,
+					Attribute: attr.name,
+					Expected:  attr.expected,
+					Actual:    attr.actual,
+				})
+			}
+		}
+	}
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func setOf(names []string) map[string]string {
+	set := make(map[string]string, len(names))
+	for _, name := range names {
+		set[name] = name
+	}
+	return set
+}
+
+func tablesByName(tables []Table) map[string]Table {
+	byName := make(map[string]Table, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+	return byName
+}
+
+func extensionDefinitions(s *Schema) map[string]string {
+	defs := map[string]string{}
+	for _, ext := range s.Extensions {
+		defs[ext.Name] = "WITH SCHEMA " + ext.Schema
+	}
+	return defs
+}
+
+func enumDefinitions(s *Schema) map[string]string {
+	defs := map[string]string{}
+	for _, enum := range s.Enums {
+		defs[enum.Name] = strings.Join(quoteLiterals(enum.Labels), ", ")
+	}
+	return defs
+}
+
+func domainDefinitions(s *Schema) map[string]string {
+	defs := map[string]string{}
+	for _, domain := range s.Domains {
+		defs[domain.Name] = domain.SQL()
+	}
+	return defs
+}
+
+func typeDefinitions(s *Schema) map[string]string {
+	defs := map[string]string{}
+	for _, typ := range s.Types {
+		defs[typ.Name] = typ.SQL()
+	}
+	return defs
+}
+
+func sequenceDefinitions(s *Schema) map[string]string {
+	defs := map[string]string{}
+	for _, seq := range s.Sequences {
+		def := seq.SQL()
+		if seq.OwnedBy != "" {
+			def += " OWNED BY " + seq.OwnedBy
+		}
+		defs[seq.Name] = def
+	}
+	return defs
+}
+
+func functionDefinitions(s *Schema) map[string]string {
+	defs := map[string]string{}
+	for _, fn := range s.Functions {
+		defs[fn.Name+"("+fn.Arguments+")"] = fn.SQL()
+	}
+	return defs
+}
+
+func viewDefinitions(s *Schema) map[string]string {
+	defs := map[string]string{}
+	for _, view := range s.Views {
+		defs[view.Name] = view.SQL()
+	}
+	return defs
+}
+
+func constraintDefinitions(t Table) map[string]string {
+	defs := map[string]string{}
+	for _, c := range t.Constraints {
+		defs[c.Name] = c.Definition
+	}
+	return defs
+}
+
+func indexDefinitions(t Table) map[string]string {
+	defs := map[string]string{}
+	for _, idx := range t.Indexes {
+		defs[idx.Name] = idx.SQL()
+	}
+	return defs
+}
+
+func triggerDefinitions(t Table) map[string]string {
+	defs := map[string]string{}
+	for _, trigger := range t.Triggers {
+		defs[trigger.Name] = trigger.SQL()
+	}
+	return defs
+}
@@ -0,0 +1,219 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	expected := &Schema{
+		Tables: []Table{
+			{
+				Name: "public.users",
+				Columns: []Column{
+					{Name: "id", Type: "integer", NotNull: true},
+					{Name: "email", Type: "text", NotNull: true},
+					{Name: "name", Type: "text"},
+				},
+				Constraints: []Constraint{{Name: "users_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"}},
+				Indexes:     []Index{{Name: "users_email_idx", Definition: "CREATE UNIQUE INDEX users_email_idx ON public.users USING btree (email)"}},
+			},
+			{Name: "public.posts", Columns: []Column{{Name: "id", Type: "integer"}}},
+		},
+		Versions: []string{"0001"},
+	}
+	actual := &Schema{
+		Tables: []Table{{
+			Name: "public.users",
+			Columns: []Column{
+				{Name: "id", Type: "integer", NotNull: true},
+				{Name: "email", Type: "character varying(255)", NotNull: false, Default: "''::character varying"},
+				{Name: "nickname", Type: "text"},
+			},
+			Constraints: []Constraint{{Name: "users_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"}},
+			Indexes:     []Index{{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (email)"}},
+		}},
+		Versions: []string{"0001", "0002"},
+	}
+
+	changes := DiffSchemas(expected, actual)
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	assert.Equal(t, []string{
+		"- table public.posts is missing: CREATE TABLE public.posts ( id integer );",
+		`~ column public.users.email type differs: expected "text", actual "character varying(255)"`,
+		`~ column public.users.email not null differs: expected "true", actual "false"`,
+		`~ column public.users.email default differs: expected "", actual "''::character varying"`,
+		"- column public.users.name is missing: name text",
+		"+ column public.users.nickname is not in the migrations: nickname text",
+		"~ index public.users.users_email_idx differs:\n" +
+			"    expected: CREATE UNIQUE INDEX users_email_idx ON public.users USING btree (email);\n" +
+			"    actual:   CREATE INDEX users_email_idx ON public.users USING btree (email);",
+	}, lines)
+
+	assert.Empty(t, DiffSchemas(expected, expected))
+}
+
+func TestMigrationSQL(t *testing.T) {
+	users := Table{
+		Name: "public.users",
+		Columns: []Column{
+			{Name: "id", Type: "integer", NotNull: true},
+			{Name: "name", Type: "text"},
+		},
+		Constraints: []Constraint{{Name: "users_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"}},
+	}
+	from := &Schema{Tables: []Table{users}}
+
+	renamed := users
+	renamed.Columns = []Column{
+		{Name: "id", Type: "integer", NotNull: true},
+		{Name: "full_name", Type: "text"},
+		{Name: "email", Type: "text", NotNull: true},
+	}
+	renamed.Indexes = []Index{{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (email)"}}
+	posts := Table{
+		Name:        "public.posts",
+		Columns:     []Column{{Name: "user_id", Type: "integer"}},
+		Constraints: []Constraint{{Name: "posts_user_id_fkey", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (user_id) REFERENCES public.users(id)"}},
+	}
+	to := &Schema{Tables: []Table{posts, renamed}}
+
+	assert.Equal(t, `-- TODO: columns name of public.users are dropped and email, full_name added. If one is renamed, replace the
+-- DROP COLUMN and ADD COLUMN with ALTER TABLE public.users RENAME COLUMN old_name TO new_name to keep its data.
+
+CREATE TABLE public.posts (
+    user_id integer
+);
+
+-- TODO: public.users.email is NOT NULL without a default; backfill it if the table has rows.
+ALTER TABLE public.users ADD COLUMN email text NOT NULL;
+
+ALTER TABLE public.users ADD COLUMN full_name text;
+
+ALTER TABLE public.users DROP COLUMN name;
+
+ALTER TABLE ONLY public.posts
+    ADD CONSTRAINT posts_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+
+CREATE INDEX users_email_idx ON public.users USING btree (email);`, migrationSQL(from, to))
+
+	assert.Equal(t, `-- TODO: columns email, full_name of public.users are dropped and name added. If one is renamed, replace the
+-- DROP COLUMN and ADD COLUMN with ALTER TABLE public.users RENAME COLUMN old_name TO new_name to keep its data.
+
+DROP INDEX public.users_email_idx;
+
+DROP TABLE public.posts;
+
+ALTER TABLE public.users DROP COLUMN email;
+
+ALTER TABLE public.users DROP COLUMN full_name;
+
+ALTER TABLE public.users ADD COLUMN name text;`, migrationSQL(to, from))
+
+	assert.Equal(t, `-- TODO: column public.users.name is dropped and full_name added. If it is a rename, replace both with:
+-- ALTER TABLE public.users RENAME COLUMN name TO full_name;
+
+ALTER TABLE public.users ADD COLUMN full_name text;
+
+ALTER TABLE public.users DROP COLUMN name;`, migrationSQL(from, &Schema{Tables: []Table{{
+		Name:        users.Name,
+		Columns:     []Column{users.Columns[0], {Name: "full_name", Type: "text"}},
+		Constraints: users.Constraints,
+	}}}))
+}
+
+func TestMigrationSQL_Partitions(t *testing.T) {
+	events := Table{Name: "public.events", PartitionBy: "RANGE (created_at)", Columns: []Column{{Name: "created_at", Type: "date"}}}
+	partition := Table{Name: "public.a_events_2025", PartitionOf: "public.events", PartitionBound: "FOR VALUES FROM ('2025-01-01') TO ('2026-01-01')",
+		Columns: events.Columns}
+	latest := Function{Name: "public.latest_events", Definition: "CREATE OR REPLACE FUNCTION public.latest_events() RETURNS SETOF public.events LANGUAGE sql AS $$ SELECT * FROM public.events $$",
+		DependsOn: []string{"public.events"}}
+	to := &Schema{Functions: []Function{latest}, Tables: []Table{partition, events}}
+
+	assert.Equal(t, `CREATE TABLE public.events (
+    created_at date
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE public.a_events_2025 PARTITION OF public.events FOR VALUES FROM ('2025-01-01') TO ('2026-01-01');
+
+CREATE OR REPLACE FUNCTION public.latest_events() RETURNS SETOF public.events LANGUAGE sql AS $$ SELECT * FROM public.events $$;`, migrationSQL(&Schema{}, to))
+
+	assert.Equal(t, `DROP FUNCTION public.latest_events();
+
+DROP TABLE public.a_events_2025;
+
+DROP TABLE public.events;`, migrationSQL(to, &Schema{}))
+
+	plain := events
+	plain.PartitionBy = ""
+	assert.Equal(t, []SchemaChange{{
+		Change: ChangeChanged, Kind: "table", Name: "public.events", Attribute: "partitioning",
+		Expected: "PARTITION BY RANGE (created_at)",
+	}}, DiffSchemas(&Schema{Tables: []Table{events}}, &Schema{Tables: []Table{plain}}))
+}
+
+func TestMigrationSQL_Types(t *testing.T) {
+	email := Domain{Name: "public.email", Type: "text", Checks: []string{"CONSTRAINT email_check CHECK ((VALUE ~~ '%@%'::text))"}}
+	amount := CompositeType{Name: "public.money_amount", Attributes: []string{"amount numeric(12,2)", "currency character(3)"}}
+	from := &Schema{Domains: []Domain{email}}
+	to := &Schema{Domains: []Domain{{Name: email.Name, Type: "text", NotNull: true}}, Types: []CompositeType{amount}}
+
+	assert.Equal(t, `-- TODO: domain public.email changes; use ALTER DOMAIN to go from
+-- CREATE DOMAIN public.email AS text
+--     CONSTRAINT email_check CHECK ((VALUE ~~ '%@%'::text));
+-- to
+-- CREATE DOMAIN public.email AS text NOT NULL;
+
+CREATE TYPE public.money_amount AS (
+    amount numeric(12,2),
+    currency character(3)
+);`, migrationSQL(from, to))
+
+	assert.Contains(t, migrationSQL(to, from), "DROP TYPE public.money_amount;")
+}
+
+func TestMigrationSQL_Views(t *testing.T) {
+	all := View{Name: "public.all_posts", Definition: " SELECT id\n   FROM public.posts;"}
+	recent := View{Name: "public.recent_posts", Definition: " SELECT id\n   FROM public.all_posts;", DependsOn: []string{"public.all_posts"}}
+	from := &Schema{Views: []View{all, recent}}
+	changed := all
+	changed.Definition = " SELECT id, title\n   FROM public.posts;"
+
+	assert.Equal(t, `DROP VIEW public.recent_posts;
+
+DROP VIEW public.all_posts;
+
+CREATE VIEW public.all_posts AS
+SELECT id, title
+   FROM public.posts;
+
+CREATE VIEW public.recent_posts AS
+SELECT id
+   FROM public.all_posts;`, migrationSQL(from, &Schema{Views: []View{changed, recent}}))
+
+	assert.Equal(t, `DROP VIEW public.recent_posts;
+
+DROP VIEW public.all_posts;`, migrationSQL(from, &Schema{}))
+}
+
+func TestMigrationSQL_FunctionSignature(t *testing.T) {
+	total := Function{Name: "public.total", Arguments: "integer", Parameters: "user_id integer", Result: "integer",
+		Definition: "CREATE OR REPLACE FUNCTION public.total(user_id integer) RETURNS integer LANGUAGE sql AS $$ SELECT 1 $$"}
+	body := total
+	body.Definition = "CREATE OR REPLACE FUNCTION public.total(user_id integer) RETURNS integer LANGUAGE sql AS $$ SELECT 2 $$"
+	assert.Equal(t, body.SQL(), migrationSQL(&Schema{Functions: []Function{total}}, &Schema{Functions: []Function{body}}))
+
+	result := total
+	result.Result = "bigint"
+	result.Definition = "CREATE OR REPLACE FUNCTION public.total(user_id integer) RETURNS bigint LANGUAGE sql AS $$ SELECT 1 $$"
+	assert.Equal(t, `-- TODO: the signature of public.total(integer) changes, so it is dropped and created again;
+-- drop and recreate the triggers, views and functions using it as well.
+
+DROP FUNCTION public.total(integer);
+
+`+result.SQL(), migrationSQL(&Schema{Functions: []Function{total}}, &Schema{Functions: []Function{result}}))
+}
@@ -0,0 +1,421 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// migrationSQL returns the statements that turn the schema from into the
+// schema to. Changes that can't be inferred safely, such as renames, get a
+// TODO comment for the author of the migration.
+func migrationSQL(from *Schema, to *Schema) string {
+	g := &sqlGenerator{from: from, to: to}
+	for _, change := range DiffSchemas(to, from) {
+		g.add(change)
+	}
+	g.renames()
+
+	viewDrops, viewCreates := g.viewSQL()
+
+	var parts []string
+	for _, phase := range [][]string{g.todos, viewDrops, g.drops, g.creates, g.tableSQL(), g.functions, g.constraints, g.foreignKeys, g.indexes, viewCreates, g.cleanups} {
+		parts = append(parts, phase...)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// sqlGenerator sorts the statements of a migration into phases, so that
+// dependent objects are dropped before and created after what they use.
+type sqlGenerator struct {
+	from *Schema
+	to   *Schema
+
+	todos       []string
+	drops       []string // triggers, indexes, constraints and functions using tables
+	creates     []string // schemas, extensions, types, sequences and functions
+	tables      []string // column changes
+	functions   []string // functions using the row type of a table
+	constraints []string
+	foreignKeys []string
+	indexes     []string // indexes and triggers
+	cleanups    []string // functions, sequences, types, extensions and schemas
+
+	// droppedViews and createdViews are the views to drop and create, in
+	// dependency order by viewSQL.
+	droppedViews map[string]bool
+	createdViews map[string]bool
+
+	// addedColumns and droppedColumns are the column names added to and
+	// dropped from each table, to detect renames.
+	addedColumns   map[string][]string
+	droppedColumns map[string][]string
+	addedTables    []string
+	droppedTables  []string
+	// createdTables and removedTables are the tables to create and drop,
+	// ordered around partitions by tableSQL.
+	createdTables []Table
+	removedTables []Table
+}
+
+func (g *sqlGenerator) add(c SchemaChange) {
+	switch c.Kind {
+	case "schema":
+		g.object(c, &g.creates, &g.cleanups, "CREATE SCHEMA "+c.Name+";", "DROP SCHEMA "+c.Name+";")
+	case "extension":
+		ext := findExtension(g.to, c.Name)
+		g.object(c, &g.creates, &g.cleanups,
+			fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s;", c.Name, ext.Schema), "DROP EXTENSION "+c.Name+";")
+	case "enum":
+		if c.Change == ChangeChanged {
+			g.todos = append(g.todos, fmt.Sprintf("-- TODO: the labels of enum %s change from (%s) to (%s).\n"+
+				"-- Use ALTER TYPE %s ADD VALUE or RENAME VALUE; labels can't be removed.", c.Name, c.Actual, c.Expected, c.Name))
+			return
+		}
+		g.object(c, &g.creates, &g.cleanups,
+			fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", c.Name, c.Expected), "DROP TYPE "+c.Name+";")
+	case "domain", "type":
+		if c.Change == ChangeChanged {
+			g.todos = append(g.todos, fmt.Sprintf("-- TODO: %s %s changes; use ALTER %s to go from\n%s\n-- to\n%s",
+				c.Kind, c.Name, strings.ToUpper(c.Kind), commentOut(c.Actual), commentOut(c.Expected)))
+			return
+		}
+		g.object(c, &g.creates, &g.cleanups, c.Expected, fmt.Sprintf("DROP %s %s;", strings.ToUpper(c.Kind), c.Name))
+	case "sequence":
+		if c.Change == ChangeChanged {
+			g.todos = append(g.todos, fmt.Sprintf("-- TODO: sequence %s changes; use ALTER SEQUENCE to go from\n%s\n-- to\n%s",
+				c.Name, commentOut(c.Actual), commentOut(c.Expected)))
+			return
+		}
+		seq := findSequence(g.to, c.Name)
+		// Dropping a table or column drops the sequences it owns.
+		g.object(c, &g.creates, &g.cleanups, seq.SQL(), "DROP SEQUENCE IF EXISTS "+c.Name+";")
+		if c.Change == ChangeMissing && seq.OwnedBy != "" {
+			g.constraints = append(g.constraints, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s;", seq.Name, seq.OwnedBy))
+		}
+	case "function":
+		if c.Change == ChangeChanged {
+			from, to := findFunction(g.from, c.Name), findFunction(g.to, c.Name)
+			if from.Parameters != to.Parameters || from.Result != to.Result {
+				// CREATE OR REPLACE can't change the parameters or the result.
+				g.todos = append(g.todos, fmt.Sprintf("-- TODO: the signature of %s changes, so it is dropped and created again;\n"+
+					"-- drop and recreate the triggers, views and functions using it as well.", c.Name))
+				g.drops = append(g.drops, "DROP FUNCTION "+c.Name+";")
+			}
+		}
+		// Functions using the row type of a table are dropped before and
+		// created after the tables.
+		switch {
+		case c.Change == ChangeExtra && len(findFunction(g.from, c.Name).DependsOn) > 0:
+			g.drops = append(g.drops, "DROP FUNCTION "+c.Name+";")
+		case c.Change == ChangeExtra:
+			g.cleanups = append(g.cleanups, "DROP FUNCTION "+c.Name+";")
+		case len(findFunction(g.to, c.Name).DependsOn) > 0:
+			g.functions = append(g.functions, c.Expected)
+		default:
+			g.creates = append(g.creates, c.Expected)
+		}
+	case "table":
+		g.table(c)
+	case "column":
+		g.column(c)
+	case "constraint":
+		drop := fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", c.Table, c.Name)
+		add := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;", c.Table, c.Name, c.Expected)
+		if c.Change != ChangeMissing {
+			g.drops = append(g.drops, drop)
+		}
+		if c.Change != ChangeExtra {
+			if findConstraint(findTable(g.to, c.Table), c.Name).Type == ConstraintForeignKey {
+				g.foreignKeys = append(g.foreignKeys, add)
+			} else {
+				g.constraints = append(g.constraints, add)
+			}
+		}
+	case "index":
+		g.object(c, &g.indexes, &g.drops, c.Expected, fmt.Sprintf("DROP INDEX %s;", qualifyInSchemaOf(c.Table, c.Name)))
+	case "trigger":
+		g.object(c, &g.indexes, &g.drops, c.Expected, fmt.Sprintf("DROP TRIGGER %s ON %s;", c.Name, c.Table))
+	case "view":
+		if g.droppedViews == nil {
+			g.droppedViews, g.createdViews = map[string]bool{}, map[string]bool{}
+		}
+		if c.Change != ChangeMissing {
+			g.droppedViews[c.Name] = true
+		}
+		if c.Change != ChangeExtra {
+			g.createdViews[c.Name] = true
+		}
+	}
+}
+
+// viewSQL returns the statements dropping views, the views reading from
+// others first, and those creating views, the views they read from first. A
+// view reading from a dropped one is dropped with it and created again, since
+// Postgres refuses to drop a view others depend on.
+func (g *sqlGenerator) viewSQL() ([]string, []string) {
+	for changed := true; changed; {
+		changed = false
+		for _, view := range g.from.Views {
+			if g.droppedViews[view.Name] {
+				continue
+			}
+			for _, dep := range view.DependsOn {
+				if g.droppedViews[dep] {
+					g.droppedViews[view.Name] = true
+					if findView(g.to, view.Name).Name != "" {
+						g.createdViews[view.Name] = true
+					}
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var drops, creates []string
+	from := sortViews(g.from.Views)
+	for i := len(from) - 1; i >= 0; i-- {
+		if view := from[i]; g.droppedViews[view.Name] {
+			kind := "VIEW"
+			if view.Materialized {
+				kind = "MATERIALIZED VIEW"
+			}
+			drops = append(drops, fmt.Sprintf("DROP %s %s;", kind, view.Name))
+		}
+	}
+	for _, view := range sortViews(g.to.Views) {
+		if g.createdViews[view.Name] {
+			creates = append(creates, view.SQL())
+		}
+	}
+	return drops, creates
+}
+
+// object sorts the statements of a change whose object is created by
+// create in phase creates and dropped by drop in phase drops. A changed
+// object is dropped and created again.
+func (g *sqlGenerator) object(c SchemaChange, creates *[]string, drops *[]string, create string, drop string) {
+	if c.Change != ChangeMissing {
+		*drops = append(*drops, drop)
+	}
+	if c.Change != ChangeExtra {
+		*creates = append(*creates, create)
+	}
+}
+
+func (g *sqlGenerator) table(c SchemaChange) {
+	switch c.Change {
+	case ChangeMissing:
+		table := findTable(g.to, c.Name)
+		g.createdTables = append(g.createdTables, table)
+		for _, constraint := range table.Constraints {
+			if constraint.Type == ConstraintForeignKey {
+				g.foreignKeys = append(g.foreignKeys, constraint.AddSQL(table.Name))
+			}
+		}
+		for _, idx := range table.Indexes {
+			g.indexes = append(g.indexes, idx.SQL())
+		}
+		for _, trigger := range table.Triggers {
+			g.indexes = append(g.indexes, trigger.SQL())
+		}
+		g.addedTables = append(g.addedTables, c.Name)
+	case ChangeExtra:
+		g.removedTables = append(g.removedTables, findTable(g.from, c.Name))
+		g.droppedTables = append(g.droppedTables, c.Name)
+	case ChangeChanged:
+		g.todos = append(g.todos, fmt.Sprintf("-- TODO: the partitioning of %s changes from %q to %q.\n"+
+			"-- Create a new table partitioned as expected, move the rows and swap the names.", c.Name, c.Actual, c.Expected))
+	}
+}
+
+// tableSQL returns the statements of the tables phase: the tables dropped,
+// partitions before their parent, then the tables created, partitions after
+// their parent, then the column changes.
+func (g *sqlGenerator) tableSQL() []string {
+	var stmts []string
+	removed := sortTables(g.removedTables)
+	for i := len(removed) - 1; i >= 0; i-- {
+		stmts = append(stmts, "DROP TABLE "+removed[i].Name+";")
+	}
+	for _, table := range sortTables(g.createdTables) {
+		stmts = append(stmts, table.SQL())
+	}
+	return append(stmts, g.tables...)
+}
+
+func (g *sqlGenerator) column(c SchemaChange) {
+	prefix := "ALTER TABLE " + c.Table
+	switch c.Change {
+	case ChangeMissing:
+		col := findColumn(findTable(g.to, c.Table), c.Name)
+		stmt := fmt.Sprintf("%s ADD COLUMN %s;", prefix, c.Expected)
+		if col.NotNull && col.Default == "" && col.Identity == "" && col.Generated == "" {
+			stmt = fmt.Sprintf("-- TODO: %s.%s is NOT NULL without a default; backfill it if the table has rows.\n%s", c.Table, c.Name, stmt)
+		}
+		g.tables = append(g.tables, stmt)
+		if g.addedColumns == nil {
+			g.addedColumns = map[string][]string{}
+		}
+		g.addedColumns[c.Table] = append(g.addedColumns[c.Table], c.Name)
+		return
+	case ChangeExtra:
+		g.tables = append(g.tables, fmt.Sprintf("%s DROP COLUMN %s;", prefix, c.Name))
+		if g.droppedColumns == nil {
+			g.droppedColumns = map[string][]string{}
+		}
+		g.droppedColumns[c.Table] = append(g.droppedColumns[c.Table], c.Name)
+		return
+	}
+
+	alter := fmt.Sprintf("%s ALTER COLUMN %s", prefix, c.Name)
+	switch c.Attribute {
+	case "type":
+		g.tables = append(g.tables, fmt.Sprintf("-- TODO: check that the values of %s.%s convert from %s, or add a USING clause.\n%s TYPE %s;",
+			c.Table, c.Name, c.Actual, alter, c.Expected))
+	case "not null":
+		if c.Expected == "true" {
+			g.tables = append(g.tables, alter+" SET NOT NULL;")
+		} else {
+			g.tables = append(g.tables, alter+" DROP NOT NULL;")
+		}
+	case "default":
+		if c.Expected == "" {
+			g.tables = append(g.tables, alter+" DROP DEFAULT;")
+		} else {
+			g.tables = append(g.tables, alter+" SET DEFAULT "+c.Expected+";")
+		}
+	default:
+		g.todos = append(g.todos, fmt.Sprintf("-- TODO: the %s of %s.%s changes from %q to %q; write the ALTER COLUMN by hand.",
+			c.Attribute, c.Table, c.Name, c.Actual, c.Expected))
+	}
+}
+
+// renames adds a TODO wherever objects are both dropped and added, since a
+// rename looks the same in the catalog and dropping loses the data.
+func (g *sqlGenerator) renames() {
+	if len(g.addedTables) > 0 && len(g.droppedTables) > 0 {
+		todo := fmt.Sprintf("-- TODO: tables %s are dropped and %s created. If one is renamed, replace the\n"+
+			"-- DROP TABLE and CREATE TABLE with ALTER TABLE old_name RENAME TO new_name to keep its data.",
+			strings.Join(g.droppedTables, ", "), strings.Join(g.addedTables, ", "))
+		if len(g.addedTables) == 1 && len(g.droppedTables) == 1 {
+			todo = fmt.Sprintf("-- TODO: table %s is dropped and %s created. If it is a rename, replace both with:\n"+
+				"-- ALTER TABLE %s RENAME TO %s;", g.droppedTables[0], g.addedTables[0], g.droppedTables[0], unqualified(g.addedTables[0]))
+		}
+		g.todos = append(g.todos, todo)
+	}
+
+	for _, table := range unionKeys(g.addedColumns, g.droppedColumns) {
+		added, dropped := g.addedColumns[table], g.droppedColumns[table]
+		if len(added) == 0 || len(dropped) == 0 {
+			continue
+		}
+		todo := fmt.Sprintf("-- TODO: columns %s of %s are dropped and %s added. If one is renamed, replace the\n"+
+			"-- DROP COLUMN and ADD COLUMN with ALTER TABLE %s RENAME COLUMN old_name TO new_name to keep its data.",
+			strings.Join(dropped, ", "), table, strings.Join(added, ", "), table)
+		if len(added) == 1 && len(dropped) == 1 {
+			todo = fmt.Sprintf("-- TODO: column %s.%s is dropped and %s added. If it is a rename, replace both with:\n"+
+				"-- ALTER TABLE %s RENAME COLUMN %s TO %s;", table, dropped[0], added[0], table, dropped[0], added[0])
+		}
+		g.todos = append(g.todos, todo)
+	}
+}
+
+// commentOut prefixes every line of sql with "-- ".
+func commentOut(sql string) string {
+	return "-- " + strings.ReplaceAll(sql, "\n", "\n-- ")
+}
+
+// splitQualified splits a name qualified as schema.name by the catalog
+// queries, where a dot inside double quotes is part of the identifier.
+func splitQualified(name string) (string, string) {
+	quoted := false
+	for i, r := range name {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			return name[:i], name[i+1:]
+		}
+	}
+	return "", name
+}
+
+// qualifyInSchemaOf qualifies name with the schema of the qualified table.
+func qualifyInSchemaOf(table string, name string) string {
+	schema, _ := splitQualified(table)
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
+
+func unqualified(name string) string {
+	_, name = splitQualified(name)
+	return name
+}
+
+func findExtension(s *Schema, name string) Extension {
+	for _, ext := range s.Extensions {
+		if ext.Name == name {
+			return ext
+		}
+	}
+	return Extension{Name: name}
+}
+
+// findFunction finds a function by its name followed by its arguments in
+// parentheses, as in the changes to functions.
+func findFunction(s *Schema, key string) Function {
+	for _, fn := range s.Functions {
+		if fn.Name+"("+fn.Arguments+")" == key {
+			return fn
+		}
+	}
+	return Function{}
+}
+
+func findSequence(s *Schema, name string) Sequence {
+	for _, seq := range s.Sequences {
+		if seq.Name == name {
+			return seq
+		}
+	}
+	return Sequence{Name: name}
+}
+
+func findTable(s *Schema, name string) Table {
+	for _, table := range s.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return Table{Name: name}
+}
+
+func findColumn(t Table, name string) Column {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return Column{Name: name}
+}
+
+func findConstraint(t Table, name string) Constraint {
+	for _, c := range t.Constraints {
+		if c.Name == name {
+			return c
+		}
+	}
+	return Constraint{Name: name}
+}
+
+func findView(s *Schema, name string) View {
+	for _, view := range s.Views {
+		if view.Name == name {
+			return view
+		}
+	}
+	return View{Name: name}
+}
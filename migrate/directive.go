@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"strings"
+)
+
+// Directives are SQL line comments of the form "-- migrate:<name> [value]".
+const directivePrefix = "-- migrate:"
+
+const (
+	directiveIrreversible = "irreversible"
+)
+
+// directive is a directive found on a line of a file.
+type directive struct {
+	line  int
+	name  string
+	value string
+}
+
+// directives returns the directives of content in order, leaving out the
+// lines that start inside a string literal, a dollar-quoted body or a block
+// comment, where "-- migrate:" is text rather than a directive.
+func directives(content string) []directive {
+	if !strings.Contains(content, directivePrefix) {
+		return nil
+	}
+	quoted := quotedLines(content)
+	var found []directive
+	for i, line := range strings.Split(content, "\n") {
+		name, value, ok := parseDirective(line)
+		if ok && !quoted[i+1] {
+			found = append(found, directive{line: i + 1, name: name, value: value})
+		}
+	}
+	return found
+}
+
+// headerDirectives returns the directives of the header of content: the
+// comment and blank lines it starts with.
+func headerDirectives(content string) []directive {
+	var found []directive
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			break
+		}
+		if name, value, ok := parseDirective(line); ok {
+			found = append(found, directive{line: i + 1, name: name, value: value})
+		}
+	}
+	return found
+}
+
+// quotedLines returns the 1-based numbers of the lines of content that start
+// inside a string literal, a dollar-quoted body or a block comment.
+func quotedLines(content string) map[int]bool {
+	quoted := map[int]bool{}
+	for _, s := range literalSpans(content, false) {
+		line := strings.Count(content[:s.start], "\n") + 1
+		for i := s.start; i < s.end && i < len(content); i++ {
+			if content[i] == '\n' {
+				line++
+				quoted[line] = true
+			}
+		}
+	}
+	return quoted
+}
+
+// findDirective returns the value of the first directive with the given name
+// and whether it was present at all.
+func findDirective(content string, name string) (string, bool) {
+	for _, d := range directives(content) {
+		if d.name == name {
+			return d.value, true
+		}
+	}
+	return "", false
+}
+
+func hasDirective(content string, name string) bool {
+	_, ok := findDirective(content, name)
+	return ok
+}
+
+func parseDirective(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, directivePrefix) {
+		return "", "", false
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(line, directivePrefix))
+	if rest == "" {
+		return "", "", false
+	}
+	name, value, _ := strings.Cut(rest, " ")
+	return name, strings.TrimSpace(value), true
+}
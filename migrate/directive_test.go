@@ -0,0 +1,22 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDirective(t *testing.T) {
+	content := "-- migrate:lock-timeout 5s\n" +
+		"INSERT INTO notes (body) VALUES ('\n-- migrate:irreversible\n');\n" +
+		"CREATE FUNCTION f() RETURNS text AS $$\n-- migrate:template\nSELECT 1\n$$ LANGUAGE sql;\n" +
+		"/*\n-- migrate:baseline\n*/\n"
+
+	value, ok := findDirective(content, directiveLockTimeout)
+	assert.True(t, ok)
+	assert.Equal(t, "5s", value)
+	assert.False(t, hasDirective(content, directiveIrreversible))
+	assert.False(t, hasDirective(content, directiveTemplate))
+	assert.False(t, hasDirective(content, directiveBaseline))
+	assert.Equal(t, []directive{{line: 1, name: directiveLockTimeout, value: "5s"}}, directives(content))
+}
@@ -0,0 +1,44 @@
+package migrate
+
+import "fmt"
+
+// Drift compares the schema of the database with the one its migrations
+// produce. The migrations recorded as applied are run on a scratch database
+// on the same server, and the differences from that expected schema to the
+// actual one are returned; none means no drift.
+func (m *Migration) Drift() ([]SchemaChange, error) {
+	actual, err := m.InspectSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+
+	applied := map[string]bool{}
+	for _, version := range actual.Versions {
+		applied[version] = true
+	}
+
+	var expected *Schema
+	err = m.withScratchDatabase(func(scratch *Migration) error {
+		scratch.UpFiles = nil
+		for _, file := range m.UpFiles {
+			if applied[file.Version()] {
+				scratch.UpFiles = append(scratch.UpFiles, file)
+			}
+		}
+		if err := scratch.Up(); err != nil {
+			return fmt.Errorf("failed to migrate scratch database: %w", err)
+		}
+
+		var err error
+		expected, err = scratch.InspectSchema()
+		if err != nil {
+			return fmt.Errorf("failed to inspect scratch database: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffSchemas(expected, actual), nil
+}
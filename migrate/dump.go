@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type schemaInspector interface {
+	InspectSchema(ctx context.Context) (*Schema, error)
+}
+
+// SchemaFile returns where the schema is dumped: the configured file, or
+// "schema.sql" next to the migrations directory.
+func (m *Migration) SchemaFile() string {
+	if m.config.Command.SchemaFile != "" {
+		return m.config.Command.SchemaFile
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(m.config.Command.MigrationDir)), "schema.sql")
+}
+
+// InspectSchema reads the current structure of the database and the
+// versions applied to it.
+func (m *Migration) InspectSchema() (*Schema, error) {
+	schema, err := m.inspector.InspectSchema(m.context())
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.schemaReader.ListAppliedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	for _, migration := range applied {
+		schema.Versions = append(schema.Versions, migration.Version)
+	}
+	return schema, nil
+}
+
+// DumpSchema writes the structure of the database as SQL to path, or to
+// SchemaFile when path is empty. The output only changes when the schema
+// or the applied versions do.
+func (m *Migration) DumpSchema(path string) error {
+	if path == "" {
+		path = m.SchemaFile()
+	}
+
+	schema, err := m.InspectSchema()
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(schema.SQL()), 0o644); err != nil {
+		return fmt.Errorf("failed to write schema file: %w", err)
+	}
+
+	m.logf("Dumped schema to %s", path)
+	return nil
+}
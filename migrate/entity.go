@@ -5,10 +5,15 @@ import "time"
 type SchemaMigration struct {
 	Version   string
 	AppliedAt time.Time
+	Batch     int
+	Checksum  string
 }
 
 type SchemaMigrationStatus struct {
 	Version   string
+	Name      string
+	Source    string // "sql" or "go"
 	AppliedAt *time.Time
-	Status    string // "up" or "pending"
+	Batch     int
+	Status    string // "up", "pending", "modified" (applied SQL changed since), or "outdated" for changed repeatable migrations
 }
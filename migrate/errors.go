@@ -0,0 +1,170 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// IrreversibleMigrationError is returned when a rollback reaches a migration
+// that has no down file or is marked with the irreversible directive.
+type IrreversibleMigrationError struct {
+	Version string
+	Reason  string
+}
+
+func (e *IrreversibleMigrationError) Error() string {
+	return fmt.Sprintf("migration %s is irreversible (%s), rollback stopped", e.Version, e.Reason)
+}
+
+// MigrationError is returned when a statement of a migration fails. Use
+// errors.As to inspect it.
+type MigrationError struct {
+	Version string
+	// File is the migration file, or the included fragment the error is in.
+	File string
+	// Statement is the failing SQL and StatementIndex its 1-based position in
+	// the migration.
+	Statement      string
+	StatementIndex int
+	// Line and Column point at the error in File, 1-based. Without a position
+	// reported by the database they point at the start of the statement.
+	Line   int
+	Column int
+	// Code is the SQLSTATE reported by the database, if any.
+	Code    string
+	Message string
+	Detail  string
+	Hint    string
+	Where   string
+	Err     error
+
+	stmt Statement
+	// line and column are Line and Column in the content the statement was
+	// split from, which differs from File once includes are expanded or
+	// template variables substituted.
+	line   int
+	column int
+}
+
+func newMigrationError(file MigrationFile, index int, stmt Statement, err error) *MigrationError {
+	e := &MigrationError{
+		Version:        file.Version(),
+		Statement:      stmt.SQL,
+		StatementIndex: index,
+		Message:        err.Error(),
+		Err:            err,
+		stmt:           stmt,
+		line:           stmt.Line,
+		column:         stmt.Column,
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		e.Code = string(pqErr.Code)
+		e.Message = pqErr.Message
+		e.Detail = pqErr.Detail
+		e.Hint = pqErr.Hint
+		e.Where = pqErr.Where
+		if position, convErr := strconv.Atoi(pqErr.Position); convErr == nil {
+			e.line, e.column = stmt.locate(position)
+		}
+	}
+	e.File, e.Line, e.Column = stmt.origin(file.Path, e.line, e.column)
+
+	return e
+}
+
+func (e *MigrationError) Error() string {
+	msg := fmt.Sprintf("migration %s failed at %s:%d:%d (statement %d): %s", e.Version, e.File, e.Line, e.Column, e.StatementIndex, e.Message)
+	if e.Code != "" {
+		msg += fmt.Sprintf(" (SQLSTATE %s)", e.Code)
+	}
+	return msg
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Snippet renders the lines of the failing statement up to the error with a
+// caret under the reported column, prefixed by the line numbers they come
+// from.
+func (e *MigrationError) Snippet() string {
+	const contextLines = 3
+
+	lines := strings.Split(e.stmt.SQL, "\n")
+	if len(lines) > 0 {
+		// The first line of the statement may start mid-line in the file.
+		lines[0] = strings.Repeat(" ", max(e.stmt.Column-1, 0)) + lines[0]
+	}
+
+	last := e.line - e.stmt.Line
+	if last < 0 || last >= len(lines) {
+		return ""
+	}
+	first := max(last-contextLines, 0)
+
+	numbers := make([]string, 0, last-first+1)
+	width := 0
+	for i := first; i <= last; i++ {
+		_, line, _ := e.stmt.origin(e.File, e.stmt.Line+i, 1)
+		numbers = append(numbers, strconv.Itoa(line))
+		width = max(width, len(numbers[len(numbers)-1]))
+	}
+	var b strings.Builder
+	for i := first; i <= last; i++ {
+		fmt.Fprintf(&b, "%*s | %s\n", width, numbers[i-first], lines[i])
+	}
+
+	// Keep tabs so the caret lines up with the text above it.
+	var pad strings.Builder
+	for i, r := range []rune(lines[last]) {
+		if i >= e.column-1 {
+			break
+		}
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+	fmt.Fprintf(&b, "%s | %s^\n", strings.Repeat(" ", width), pad.String())
+
+	return b.String()
+}
+
+// locate converts a 1-based character position inside the statement into a
+// line and column in the migration file.
+func (s Statement) locate(position int) (int, int) {
+	line, column := s.Line, s.Column
+	for i, r := range []rune(s.SQL) {
+		if i >= position-1 {
+			break
+		}
+		if r == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+	return line, column
+}
+
+// origin maps a line and column of the content the statement was split from
+// to the file, line and column they come from, file being the migration file
+// unless they come from an included fragment.
+func (s Statement) origin(file string, line int, column int) (string, int, int) {
+	if s.source == nil {
+		return file, line, column
+	}
+	origin, line, column := s.source.origin(line, column)
+	if origin == "" {
+		origin = file
+	}
+	return origin, line, column
+}
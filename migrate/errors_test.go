@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationError(t *testing.T) {
+	file := MigrationFile{Path: "db/migrations/20250101_init.up.sql", Kind: "up"}
+	stmt := Statement{
+		SQL:    "CREATE TABLE users (\n\tid SERIAL PRIMARY KEY,\n\tnmae TEXT REFERENCES missing (id)\n)",
+		Line:   4,
+		Column: 3,
+	}
+	pqErr := &pq.Error{
+		Code:     "42P01",
+		Message:  `relation "missing" does not exist`,
+		Hint:     "create it first",
+		Position: "68",
+	}
+
+	err := fmt.Errorf("wrapped: %w", newMigrationError(file, 2, stmt, pqErr))
+
+	var migrationErr *MigrationError
+	if !assert.True(t, errors.As(err, &migrationErr)) {
+		return
+	}
+	assert.Equal(t, "20250101", migrationErr.Version)
+	assert.Equal(t, 2, migrationErr.StatementIndex)
+	assert.Equal(t, 6, migrationErr.Line)
+	assert.Equal(t, 23, migrationErr.Column)
+	assert.Equal(t, "42P01", migrationErr.Code)
+	assert.Equal(t, "create it first", migrationErr.Hint)
+	assert.ErrorIs(t, err, pqErr)
+	assert.Equal(t,
+		"4 |   CREATE TABLE users (\n"+
+			"5 | \tid SERIAL PRIMARY KEY,\n"+
+			"6 | \tnmae TEXT REFERENCES missing (id)\n"+
+			"  | \t"+strings.Repeat(" ", 21)+"^\n",
+		migrationErr.Snippet())
+}
+
+func TestMigrationError_WithoutPosition(t *testing.T) {
+	file := MigrationFile{Path: "20250101_init.up.sql", Kind: "up"}
+	stmt := Statement{SQL: "SELECT 1", Line: 2, Column: 5}
+
+	err := newMigrationError(file, 1, stmt, errors.New("connection reset"))
+
+	assert.Equal(t, 2, err.Line)
+	assert.Equal(t, 5, err.Column)
+	assert.Equal(t, "", err.Code)
+	assert.Equal(t, "2 |     SELECT 1\n  |     ^\n", err.Snippet())
+}
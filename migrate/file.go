@@ -1,13 +1,35 @@
 package migrate
 
 import (
+	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+// migrationFileNamePattern matches "<version>_<name>.<up|down>.sql" where the
+// version is made of digits only. The ".go" extension is used by the virtual
+// file names of registered Go migrations.
+var migrationFileNamePattern = regexp.MustCompile(`^([0-9]+)_([A-Za-z0-9][A-Za-z0-9_\-]*)\.(up|down)\.(sql|go)$`)
+
+// RepeatableDir is the folder inside the migrations directory holding
+// repeatable migrations, which can also be named "R_<name>.sql" at the top level.
+const RepeatableDir = "repeatable"
+
+// KindRepeatable is the Kind of repeatable migrations, re-applied after the
+// versioned ones whenever their checksum changes.
+const KindRepeatable = "repeatable"
+
+// repeatableFileNamePattern matches "R_<name>.sql"; the prefix is optional
+// inside RepeatableDir.
+var repeatableFileNamePattern = regexp.MustCompile(`^(?:R_)?([A-Za-z0-9][A-Za-z0-9_\-]*)\.sql$`)
+
 type MigrationFile struct {
 	Path string
 	Kind string
+
+	// fn is set for migrations registered with Register instead of read from a file.
+	fn GoMigrationFunc
 }
 
 func (mf *MigrationFile) IsUp() bool {
@@ -18,10 +40,71 @@ func (mf *MigrationFile) IsDown() bool {
 	return mf.Kind == "down"
 }
 
+// IsGo reports whether the migration is implemented in Go rather than SQL.
+func (mf *MigrationFile) IsGo() bool {
+	return mf.fn != nil
+}
+
+// IsRepeatable reports whether the migration is re-applied whenever it changes.
+func (mf *MigrationFile) IsRepeatable() bool {
+	return mf.Kind == KindRepeatable
+}
+
+// IsSeed reports whether the file is a seed rather than a migration.
+func (mf *MigrationFile) IsSeed() bool {
+	return mf.Kind == KindSeed
+}
+
+// IsSchema reports whether the file is a schema dump rather than a migration.
+func (mf *MigrationFile) IsSchema() bool {
+	return mf.Kind == KindSchema
+}
+
+// Version returns the version prefix of the file name, or "" for repeatable
+// migrations, seeds and schema dumps.
 func (mf *MigrationFile) Version() string {
+	if mf.IsRepeatable() || mf.IsSeed() || mf.IsSchema() {
+		return ""
+	}
 	parts := strings.Split(filepath.Base(mf.Path), "_")
 	if len(parts) < 2 {
 		return ""
 	}
 	return parts[0]
 }
+
+// Name returns the descriptive part of the file name, e.g. "create-user" for
+// "20250830133803_create-user.up.sql".
+func (mf *MigrationFile) Name() string {
+	if mf.IsSeed() {
+		base := filepath.Base(mf.Path)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if mf.IsRepeatable() {
+		name, _ := parseRepeatableFileName(filepath.Base(mf.Path))
+		return name
+	}
+	_, name, _, err := ParseMigrationFileName(filepath.Base(mf.Path))
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// ParseMigrationFileName splits a file name of the form
+// "<version>_<name>.<up|down>.sql" into its parts.
+func ParseMigrationFileName(fileName string) (version string, name string, kind string, err error) {
+	matches := migrationFileNamePattern.FindStringSubmatch(fileName)
+	if matches == nil {
+		return "", "", "", fmt.Errorf("invalid migration file name %q, expected <version>_<name>.up.sql or <version>_<name>.down.sql", fileName)
+	}
+	return matches[1], matches[2], matches[3], nil
+}
+
+func parseRepeatableFileName(fileName string) (string, error) {
+	matches := repeatableFileNamePattern.FindStringSubmatch(fileName)
+	if matches == nil {
+		return "", fmt.Errorf("invalid repeatable migration file name %q, expected R_<name>.sql", fileName)
+	}
+	return matches[1], nil
+}
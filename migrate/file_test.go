@@ -139,4 +139,4 @@ func TestMigrationFile_Version(t *testing.T) {
 			assert.Equal(t, tt.want, got)
 		})
 	}
-}
\ No newline at end of file
+}
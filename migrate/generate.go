@@ -0,0 +1,319 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Version schemes used by Generate to number new migrations.
+const (
+	// VersionTimestamp numbers migrations with the local time, to the second.
+	VersionTimestamp = "timestamp"
+	// VersionUTC numbers migrations with the UTC time, to the second.
+	VersionUTC = "utc"
+	// VersionSequential numbers migrations 0001, 0002, ... following the
+	// highest existing version.
+	VersionSequential = "sequential"
+)
+
+// sequentialWidth is the number of digits of the first sequential version.
+const sequentialWidth = 4
+
+var migrationNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_\-]*$`)
+
+// TemplateData is passed to the templates of generated migration files.
+type TemplateData struct {
+	Version string
+	Name    string
+	// Table is Name without a leading "create_", the usual table name of a
+	// "create_<table>" migration.
+	Table     string
+	CreatedAt time.Time
+}
+
+type fileTemplate struct {
+	up   string
+	down string
+}
+
+const defaultTemplate = "default"
+
+// builtinTemplates are the kinds available to Generate without a template
+// directory. A kind in the template directory replaces the built-in one.
+var builtinTemplates = map[string]fileTemplate{
+	defaultTemplate: {
+		up:   "-- Migration {{.Version}} {{.Name}}\n-- Created at: {{.CreatedAt.Format \"2006-01-02 15:04:05 MST\"}}\n\n-- Write your SQL here\n",
+		down: "-- Migration {{.Version}} {{.Name}}\n-- Created at: {{.CreatedAt.Format \"2006-01-02 15:04:05 MST\"}}\n\n-- Write your SQL here\n",
+	},
+	"table": {
+		up:   "CREATE TABLE {{.Table}} (\n    id BIGSERIAL PRIMARY KEY,\n    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),\n    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()\n);\n",
+		down: "DROP TABLE IF EXISTS {{.Table}};\n",
+	},
+	"index": {
+		up:   "CREATE INDEX {{.Name}} ON table_name (column_name);\n",
+		down: "DROP INDEX IF EXISTS {{.Name}};\n",
+	},
+	"data": {
+		up:   "-- Keep data migrations idempotent and update large tables in batches.\nUPDATE table_name SET column_name = column_name WHERE false;\n",
+		down: "-- migrate:irreversible\n-- Data changes can't be undone automatically.\n",
+	},
+}
+
+// templateDir returns the directory of custom generate templates: the
+// configured one, or "templates" next to the migrations directory.
+func (m *Migration) templateDir() string {
+	if m.config.Command.TemplateDir != "" {
+		return m.config.Command.TemplateDir
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(m.config.Command.MigrationDir)), "templates")
+}
+
+// Templates returns the template kinds Generate accepts, sorted.
+func (m *Migration) Templates() ([]string, error) {
+	kinds := map[string]bool{}
+	for kind := range builtinTemplates {
+		kinds[kind] = true
+	}
+	paths, err := filepath.Glob(filepath.Join(m.templateDir(), "*.up.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	for _, path := range paths {
+		kinds[strings.TrimSuffix(filepath.Base(path), ".up.sql")] = true
+	}
+
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadTemplate returns the up and down templates of kind, read from
+// "<kind>.up.sql" and "<kind>.down.sql" in the template directory when
+// present there.
+func (m *Migration) loadTemplate(kind string) (fileTemplate, error) {
+	if kind == "" {
+		kind = defaultTemplate
+	}
+
+	dir := m.templateDir()
+	up, upErr := os.ReadFile(filepath.Join(dir, kind+".up.sql"))
+	down, downErr := os.ReadFile(filepath.Join(dir, kind+".down.sql"))
+	switch {
+	case upErr == nil && downErr == nil:
+		return fileTemplate{up: string(up), down: string(down)}, nil
+	case upErr == nil || downErr == nil:
+		return fileTemplate{}, fmt.Errorf("template %s needs both %s.up.sql and %s.down.sql in %s", kind, kind, kind, dir)
+	case !os.IsNotExist(upErr):
+		return fileTemplate{}, fmt.Errorf("failed to read template %s: %w", kind, upErr)
+	}
+
+	tmpl, ok := builtinTemplates[kind]
+	if !ok {
+		kinds, err := m.Templates()
+		if err != nil {
+			return fileTemplate{}, err
+		}
+		return fileTemplate{}, fmt.Errorf("unknown template %s, available: %s", kind, strings.Join(kinds, ", "))
+	}
+	return tmpl, nil
+}
+
+// NextVersion returns the version of a migration generated at now under the
+// configured version scheme. Loaded migrations must be up to date, since
+// the sequential scheme follows the highest of them.
+func (m *Migration) NextVersion(now time.Time) (string, error) {
+	switch scheme := m.config.Command.VersionScheme; scheme {
+	case "", VersionTimestamp:
+		return now.Format("20060102150405"), nil
+	case VersionUTC:
+		return now.UTC().Format("20060102150405"), nil
+	case VersionSequential:
+		return nextSequentialVersion(m.Versions())
+	default:
+		return "", fmt.Errorf("unknown version scheme %s, expected %s, %s or %s", scheme, VersionTimestamp, VersionUTC, VersionSequential)
+	}
+}
+
+// nextSequentialVersion returns the highest of versions plus one, padded to
+// the same width.
+func nextSequentialVersion(versions []string) (string, error) {
+	width := sequentialWidth
+	var highest uint64
+	for _, version := range versions {
+		n, err := strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid version %s: %w", version, err)
+		}
+		if n >= highest {
+			highest = n
+			width = max(width, len(version))
+		}
+	}
+	return fmt.Sprintf("%0*d", width, highest+1), nil
+}
+
+// Generate writes the up and down files of a new migration named name from
+// the template of kind, or the default one when kind is empty, and returns
+// their paths. It refuses a version that already exists.
+func (m *Migration) Generate(name string, kind string, now time.Time) ([]string, error) {
+	if err := validateMigrationName(name); err != nil {
+		return nil, err
+	}
+	tmpl, err := m.loadTemplate(kind)
+	if err != nil {
+		return nil, err
+	}
+	version, err := m.newVersion(now)
+	if err != nil {
+		return nil, err
+	}
+
+	data := TemplateData{
+		Version:   version,
+		Name:      name,
+		Table:     strings.TrimPrefix(name, "create_"),
+		CreatedAt: now,
+	}
+	contents := map[string]string{"up": tmpl.up, "down": tmpl.down}
+	rendered := map[string][]byte{}
+	for direction, content := range contents {
+		var buf bytes.Buffer
+		t, err := template.New(direction).Option("missingkey=error").Parse(content)
+		if err == nil {
+			err = t.Execute(&buf, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s template: %w", direction, err)
+		}
+		rendered[direction] = buf.Bytes()
+	}
+
+	return m.writeMigration(version, name, rendered["up"], rendered["down"])
+}
+
+func validateMigrationName(name string) error {
+	if !migrationNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid migration name %q: use letters, digits, '_' and '-'", name)
+	}
+	return nil
+}
+
+// newVersion returns the version of a migration generated at now, refusing
+// one that already exists.
+func (m *Migration) newVersion(now time.Time) (string, error) {
+	version, err := m.NextVersion(now)
+	if err != nil {
+		return "", err
+	}
+	if m.FindFileByVersion(version, "up") != nil || m.FindFileByVersion(version, "down") != nil {
+		return "", fmt.Errorf("migration version %s already exists", version)
+	}
+	return version, nil
+}
+
+// writeMigration creates the up and down files of a migration and returns
+// their paths. Neither file is left behind when one can't be written.
+func (m *Migration) writeMigration(version string, name string, up []byte, down []byte) ([]string, error) {
+	var paths []string
+	for _, file := range []struct {
+		direction string
+		content   []byte
+	}{{"up", up}, {"down", down}} {
+		path := filepath.Join(m.config.Command.MigrationDir, fmt.Sprintf("%s_%s.%s.sql", version, name, file.direction))
+		if err := writeNewFile(path, file.content); err != nil {
+			removeFiles(paths)
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeNewFile writes content to path, failing if the file already exists.
+func writeNewFile(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write migration file %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// DiffSourceDatabase is the source of GenerateDiff standing for the
+// connected database.
+const DiffSourceDatabase = "db"
+
+// GenerateDiff writes a new migration turning the schema the migrations
+// produce into a desired one: the schema created by the SQL file at source,
+// or the schema of the connected database when source is DiffSourceDatabase.
+// Both schemas are built in scratch databases on the same server. The down
+// file reverts the up file, and changes that can't be inferred safely, such
+// as renames, are marked with TODO comments.
+func (m *Migration) GenerateDiff(name string, source string, now time.Time) ([]string, error) {
+	if err := validateMigrationName(name); err != nil {
+		return nil, err
+	}
+	version, err := m.newVersion(now)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *Schema
+	err = m.withScratchDatabase(func(scratch *Migration) error {
+		if err := scratch.Up(); err != nil {
+			return fmt.Errorf("failed to migrate scratch database: %w", err)
+		}
+		var err error
+		current, err = scratch.InspectSchema()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var desired *Schema
+	if source == DiffSourceDatabase {
+		desired, err = m.InspectSchema()
+	} else {
+		err = m.withScratchDatabase(func(scratch *Migration) error {
+			if err := scratch.LoadSchema(source, true); err != nil {
+				return fmt.Errorf("failed to load desired schema: %w", err)
+			}
+			var err error
+			desired, err = scratch.InspectSchema()
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(DiffSchemas(desired, current)) == 0 {
+		return nil, fmt.Errorf("the migrations already produce the schema of %s, nothing to generate", source)
+	}
+
+	header := fmt.Sprintf("-- Generated from the differences with %s.\n-- Review the statements and resolve the TODOs before applying.\n\n", source)
+	up := header + migrationSQL(current, desired) + "\n"
+	down := header + migrationSQL(desired, current) + "\n"
+	return m.writeMigration(version, name, []byte(up), []byte(down))
+}
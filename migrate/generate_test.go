@@ -0,0 +1,73 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextSequentialVersion(t *testing.T) {
+	tests := []struct {
+		versions []string
+		want     string
+	}{
+		{nil, "0001"},
+		{[]string{"0001", "0009"}, "0010"},
+		{[]string{"000123"}, "000124"},
+		{[]string{"20250101120000"}, "20250101120001"},
+	}
+	for _, tt := range tests {
+		got, err := nextSequentialVersion(tt.versions)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestMigration_Generate(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "migrations")
+	if err := os.MkdirAll(filepath.Join(root, "templates"), 0o755); err != nil {
+		t.Fatalf("failed to create template dir: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create migration dir: %v", err)
+	}
+	m := &Migration{
+		statusGetter: &mockStatusGetter{},
+		config:       &config.Config{Command: config.CmdConfig{MigrationDir: dir, VersionScheme: VersionUTC}},
+	}
+	now := time.Date(2025, 3, 1, 9, 30, 0, 0, time.FixedZone("JST", 9*60*60))
+
+	paths, err := m.Generate("create_users", "table", now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "20250301003000_create_users.up.sql"),
+		filepath.Join(dir, "20250301003000_create_users.down.sql"),
+	}, paths)
+	down, _ := os.ReadFile(paths[1])
+	assert.Equal(t, "DROP TABLE IF EXISTS users;\n", string(down))
+
+	assert.NoError(t, m.Load(dir))
+	_, err = m.Generate("create_posts", "", now)
+	assert.EqualError(t, err, "migration version 20250301003000 already exists")
+
+	_, err = m.Generate("add users", "", now.Add(time.Second))
+	assert.ErrorContains(t, err, "invalid migration name")
+	_, err = m.Generate("backfill", "audit", now.Add(time.Second))
+	assert.EqualError(t, err, "unknown template audit, available: data, default, index, table")
+
+	os.WriteFile(filepath.Join(root, "templates", "audit.up.sql"), []byte("-- {{.Version}} {{.Name}}\n"), 0o644)
+	_, err = m.Generate("backfill", "audit", now.Add(time.Second))
+	assert.ErrorContains(t, err, "template audit needs both audit.up.sql and audit.down.sql")
+
+	os.WriteFile(filepath.Join(root, "templates", "audit.down.sql"), []byte("-- migrate:irreversible\n"), 0o644)
+	m.config.Command.VersionScheme = VersionSequential
+	paths, err = m.Generate("backfill", "audit", now)
+	assert.NoError(t, err)
+	up, _ := os.ReadFile(paths[0])
+	assert.Equal(t, "-- 20250301003001 backfill\n", string(up))
+}
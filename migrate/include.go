@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// directiveInclude inlines a fragment file from the shared directory:
+// "-- migrate:include triggers/updated_at.sql".
+const directiveInclude = "include"
+
+// sharedDir returns the directory include directives are resolved against:
+// the configured one, or "shared" next to the migrations directory.
+func (m *Migration) sharedDir() string {
+	if m.config == nil {
+		return "shared"
+	}
+	if m.config.Command.SharedDir != "" {
+		return m.config.Command.SharedDir
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(m.config.Command.MigrationDir)), "shared")
+}
+
+// expandIncludes replaces every include directive line of content with the
+// content of the fragment it names, recursively, returning the map of the
+// expanded lines back to their files. Directives inside string literals,
+// dollar-quoted bodies and block comments are left alone. stack holds the
+// files being expanded, outermost first, to detect cycles.
+func (m *Migration) expandIncludes(content string, stack []string) (string, sourceMap, error) {
+	file := stack[len(stack)-1]
+	if !strings.Contains(content, directivePrefix+directiveInclude) {
+		return content, newSourceMap(file, content), nil
+	}
+
+	quoted := quotedLines(content)
+	var out []string
+	var sm sourceMap
+	for i, line := range strings.Split(content, "\n") {
+		name, value, ok := parseDirective(line)
+		if !ok || name != directiveInclude || quoted[i+1] {
+			out = append(out, line)
+			sm = append(sm, sourceLine{file: file, line: i + 1})
+			continue
+		}
+		if value == "" {
+			return "", nil, fmt.Errorf("%s line %d: include directive without a path", file, i+1)
+		}
+
+		path, err := m.resolveInclude(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s line %d: %w", file, i+1, err)
+		}
+		for _, including := range stack {
+			if including == path {
+				return "", nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), path)
+			}
+		}
+
+		fragment, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s line %d: failed to read included file: %w", file, i+1, err)
+		}
+		expanded, fragmentMap, err := m.expandIncludes(string(fragment), append(stack[:len(stack):len(stack)], path))
+		if err != nil {
+			return "", nil, err
+		}
+		trimmed := strings.TrimRight(expanded, "\n")
+		out = append(out, trimmed)
+		if trimmed == "" {
+			// An empty fragment leaves an empty line where the directive was.
+			sm = append(sm, sourceLine{file: file, line: i + 1})
+		} else {
+			sm = append(sm, fragmentMap[:strings.Count(trimmed, "\n")+1]...)
+		}
+	}
+	return strings.Join(out, "\n"), sm, nil
+}
+
+// resolveInclude turns an include path into a file path inside the shared
+// directory, refusing paths that escape it.
+func (m *Migration) resolveInclude(include string) (string, error) {
+	dir := filepath.Clean(m.sharedDir())
+	path := filepath.Join(dir, include)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(include) {
+		return "", fmt.Errorf("included file %s is outside the shared directory %s", include, dir)
+	}
+	return path, nil
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigration_ReadContentIncludes(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) string {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		return path
+	}
+	m := &Migration{config: &config.Config{Command: config.CmdConfig{MigrationDir: filepath.Join(root, "migrations")}}}
+
+	write("shared/audit.sql", "-- migrate:include triggers/touch.sql\nALTER TABLE ${table} ADD updated_at TIMESTAMPTZ;\n")
+	write("shared/triggers/touch.sql", "CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN RETURN NEW; END $$ LANGUAGE plpgsql;\n")
+	file := MigrationFile{Path: write("migrations/20250101_users.up.sql", "CREATE TABLE users (id INT);\n-- migrate:include audit.sql\nSELECT 1;\n"), Kind: "up"}
+
+	got, err := m.readContent(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (id INT);\n"+
+		"CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN RETURN NEW; END $$ LANGUAGE plpgsql;\n"+
+		"ALTER TABLE ${table} ADD updated_at TIMESTAMPTZ;\n"+
+		"SELECT 1;\n", got)
+
+	before, err := m.checksum(file)
+	assert.NoError(t, err)
+	write("shared/triggers/touch.sql", "CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN NEW.updated_at = now(); RETURN NEW; END $$ LANGUAGE plpgsql;\n")
+	after, err := m.checksum(file)
+	assert.NoError(t, err)
+	assert.NotEqual(t, before, after)
+
+	write("shared/a.sql", "-- migrate:include b.sql\n")
+	write("shared/b.sql", "-- migrate:include a.sql\n")
+	cyclic := MigrationFile{Path: write("migrations/20250102_cycle.up.sql", "-- migrate:include a.sql\n"), Kind: "up"}
+	_, err = m.readContent(cyclic)
+	assert.EqualError(t, err, "include cycle: "+cyclic.Path+" -> "+filepath.Join(root, "shared", "a.sql")+" -> "+
+		filepath.Join(root, "shared", "b.sql")+" -> "+filepath.Join(root, "shared", "a.sql"))
+
+	escaping := MigrationFile{Path: write("migrations/20250103_escape.up.sql", "SELECT 1;\n-- migrate:include ../migrations/20250101_users.up.sql\n"), Kind: "up"}
+	_, err = m.readContent(escaping)
+	assert.ErrorContains(t, err, "line 2: included file ../migrations/20250101_users.up.sql is outside the shared directory")
+
+	m.config.Command.SharedDir = filepath.Join(root, "shared", "triggers")
+	configured := MigrationFile{Path: write("migrations/20250104_touch.up.sql", "-- migrate:include touch.sql"), Kind: "up"}
+	got, err = m.readContent(configured)
+	assert.NoError(t, err)
+	assert.Contains(t, got, "NEW.updated_at = now()")
+}
+
+func TestMigration_ReadStatementsPositions(t *testing.T) {
+	root := t.TempDir()
+	shared := filepath.Join(root, "shared")
+	assert.NoError(t, os.MkdirAll(shared, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(shared, "touch.sql"), []byte("SELECT 1;\nSELEC broken;\n"), 0o644))
+	file := MigrationFile{Path: filepath.Join(root, "20250101_users.up.sql"), Kind: "up"}
+	assert.NoError(t, os.WriteFile(file.Path, []byte("-- migrate:template\n"+
+		"CREATE TABLE ${schema}.users (id INT);\n"+
+		"-- migrate:include touch.sql\n"+
+		"SELECT '\n-- migrate:include touch.sql\n';\n"+
+		"ALTER TABLE ${schema}.users ADD x INT;\n"), 0o644))
+	m := &Migration{config: &config.Config{Command: config.CmdConfig{
+		MigrationDir: root,
+		SharedDir:    shared,
+		Vars:         map[string]string{"schema": "tenant_acme"},
+	}}}
+
+	statements, err := m.readStatements(file)
+	if !assert.NoError(t, err) || !assert.Len(t, statements, 5) {
+		return
+	}
+	// The directive inside the string literal is left as is.
+	assert.Equal(t, "SELECT '\n-- migrate:include touch.sql\n'", statements[3].SQL)
+
+	broken := newMigrationError(file, 3, statements[2], &pq.Error{Message: "syntax error", Position: "1"})
+	assert.Equal(t, filepath.Join(shared, "touch.sql"), broken.File)
+	assert.Equal(t, 2, broken.Line)
+	assert.Equal(t, 1, broken.Column)
+	assert.Equal(t, "2 | SELEC broken\n  | ^\n", broken.Snippet())
+
+	alter := statements[4]
+	missing := newMigrationError(file, 5, alter, &pq.Error{Message: `relation "tenant_acme.users" does not exist`, Position: "25"})
+	assert.Equal(t, file.Path, missing.File)
+	assert.Equal(t, 7, missing.Line)
+	assert.Equal(t, 23, missing.Column)
+	assert.Equal(t, "7 | ALTER TABLE tenant_acme.users ADD x INT\n  | "+strings.Repeat(" ", 24)+"^\n", missing.Snippet())
+
+	schema := newMigrationError(file, 5, alter, &pq.Error{Message: `schema "tenant_acme" does not exist`, Position: "15"})
+	assert.Equal(t, 7, schema.Line)
+	assert.Equal(t, 13, schema.Column)
+}
@@ -0,0 +1,387 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// userNamespace filters out the system schemas of the namespace aliased n.
+const userNamespace = `n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'`
+
+// notExtensionMember filters out objects created by an extension; the two
+// placeholders are the catalog of the object and its oid.
+const notExtensionMember = `NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = '%s'::regclass AND d.objid = %s AND d.deptype = 'e')`
+
+// InspectSchema reads the structure of the database, leaving out the tables
+// the tool itself maintains.
+func (r *repository) InspectSchema(ctx context.Context) (*Schema, error) {
+	return inspectDatabase(ctx, r.db, r.table, r.metaTable(), r.seedTable)
+}
+
+// inspectDatabase reads the structure of db in a read-only transaction.
+// excluded are quoted table names left out of the result.
+func inspectDatabase(ctx context.Context, db *sql.DB, excluded ...string) (*Schema, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	excludedOIDs := []int64{}
+	for _, table := range excluded {
+		var oid sql.NullInt64
+		if err := tx.QueryRowContext(ctx, "SELECT to_regclass($1)::oid", table).Scan(&oid); err != nil {
+			return nil, fmt.Errorf("failed to resolve table %s: %w", table, err)
+		}
+		if oid.Valid {
+			excludedOIDs = append(excludedOIDs, oid.Int64)
+		}
+	}
+
+	// An empty search_path makes the catalog functions qualify every name,
+	// whatever the search_path of the connection.
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path = ''"); err != nil {
+		return nil, fmt.Errorf("failed to reset search_path: %w", err)
+	}
+
+	i := &inspector{ctx: ctx, tx: tx, excluded: pq.Array(excludedOIDs)}
+	schema := &Schema{}
+	steps := []struct {
+		what string
+		fn   func(*Schema) error
+	}{
+		{"schemas", i.schemas},
+		{"extensions", i.extensions},
+		{"enum types", i.enums},
+		{"domains", i.domains},
+		{"composite types", i.compositeTypes},
+		{"sequences", i.sequences},
+		{"functions", i.functions},
+		{"tables", i.tables},
+		{"views", i.views},
+	}
+	for _, step := range steps {
+		if err := step.fn(schema); err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", step.what, err)
+		}
+	}
+	return schema, nil
+}
+
+type inspector struct {
+	ctx      context.Context
+	tx       *sql.Tx
+	excluded any
+}
+
+// query runs q and calls scan for every row.
+func (i *inspector) query(q string, scan func(*sql.Rows) error, args ...any) error {
+	rows, err := i.tx.QueryContext(i.ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (i *inspector) schemas(s *Schema) error {
+	q := `SELECT quote_ident(n.nspname) FROM pg_namespace n
+		WHERE ` + userNamespace + ` AND n.nspname <> 'public'
+		AND ` + fmt.Sprintf(notExtensionMember, "pg_namespace", "n.oid") + `
+		ORDER BY n.nspname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		s.Schemas = append(s.Schemas, name)
+		return nil
+	})
+}
+
+func (i *inspector) extensions(s *Schema) error {
+	q := `SELECT quote_ident(e.extname), quote_ident(n.nspname)
+		FROM pg_extension e JOIN pg_namespace n ON n.oid = e.extnamespace
+		WHERE e.extname <> 'plpgsql'
+		ORDER BY e.extname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var ext Extension
+		if err := rows.Scan(&ext.Name, &ext.Schema); err != nil {
+			return err
+		}
+		s.Extensions = append(s.Extensions, ext)
+		return nil
+	})
+}
+
+func (i *inspector) enums(s *Schema) error {
+	q := `SELECT format('%I.%I', n.nspname, t.typname),
+			ARRAY(SELECT e.enumlabel::text FROM pg_enum e WHERE e.enumtypid = t.oid ORDER BY e.enumsortorder)
+		FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE t.typtype = 'e' AND ` + userNamespace + `
+		AND ` + fmt.Sprintf(notExtensionMember, "pg_type", "t.oid") + `
+		ORDER BY n.nspname, t.typname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var enum Enum
+		if err := rows.Scan(&enum.Name, pq.Array(&enum.Labels)); err != nil {
+			return err
+		}
+		s.Enums = append(s.Enums, enum)
+		return nil
+	})
+}
+
+func (i *inspector) domains(s *Schema) error {
+	q := `SELECT format('%I.%I', n.nspname, t.typname), format_type(t.typbasetype, t.typtypmod),
+			COALESCE(t.typdefault, ''), t.typnotnull,
+			ARRAY(SELECT format('CONSTRAINT %I %s', c.conname, pg_get_constraintdef(c.oid))
+				FROM pg_constraint c WHERE c.contypid = t.oid AND c.contype = 'c' ORDER BY c.conname)
+		FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE t.typtype = 'd' AND ` + userNamespace + `
+		AND ` + fmt.Sprintf(notExtensionMember, "pg_type", "t.oid") + `
+		ORDER BY n.nspname, t.typname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var domain Domain
+		if err := rows.Scan(&domain.Name, &domain.Type, &domain.Default, &domain.NotNull, pq.Array(&domain.Checks)); err != nil {
+			return err
+		}
+		s.Domains = append(s.Domains, domain)
+		return nil
+	})
+}
+
+// compositeTypes reads the types created with CREATE TYPE ... AS, leaving out
+// the row types of tables and views.
+func (i *inspector) compositeTypes(s *Schema) error {
+	q := `SELECT format('%I.%I', n.nspname, t.typname),
+			ARRAY(SELECT format('%I %s', a.attname, format_type(a.atttypid, a.atttypmod))
+				FROM pg_attribute a WHERE a.attrelid = t.typrelid AND a.attnum > 0 AND NOT a.attisdropped
+				ORDER BY a.attnum)
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_class c ON c.oid = t.typrelid
+		WHERE t.typtype = 'c' AND c.relkind = 'c' AND ` + userNamespace + `
+		AND ` + fmt.Sprintf(notExtensionMember, "pg_type", "t.oid") + `
+		ORDER BY n.nspname, t.typname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var typ CompositeType
+		if err := rows.Scan(&typ.Name, pq.Array(&typ.Attributes)); err != nil {
+			return err
+		}
+		s.Types = append(s.Types, typ)
+		return nil
+	})
+}
+
+// sequences leaves out the sequences of identity columns, which are part of
+// the column definition.
+func (i *inspector) sequences(s *Schema) error {
+	q := `SELECT format('%I.%I', n.nspname, c.relname), format_type(s.seqtypid, NULL),
+			s.seqstart, s.seqincrement, s.seqmin, s.seqmax, s.seqcache, s.seqcycle,
+			COALESCE((SELECT format('%I.%I.%I', tn.nspname, tc.relname, a.attname)
+				FROM pg_depend d
+				JOIN pg_class tc ON tc.oid = d.refobjid
+				JOIN pg_namespace tn ON tn.oid = tc.relnamespace
+				JOIN pg_attribute a ON a.attrelid = tc.oid AND a.attnum = d.refobjsubid
+				WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'a'
+				AND tc.oid <> ALL($1::oid[])
+				LIMIT 1), '')
+		FROM pg_sequence s
+		JOIN pg_class c ON c.oid = s.seqrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE ` + userNamespace + `
+		AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype IN ('e', 'i'))
+		AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'a' AND d.refobjid = ANY($1::oid[]))
+		ORDER BY n.nspname, c.relname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var seq Sequence
+		if err := rows.Scan(&seq.Name, &seq.Type, &seq.Start, &seq.Increment, &seq.Min, &seq.Max, &seq.Cache, &seq.Cycle, &seq.OwnedBy); err != nil {
+			return err
+		}
+		s.Sequences = append(s.Sequences, seq)
+		return nil
+	}, i.excluded)
+}
+
+func (i *inspector) functions(s *Schema) error {
+	q := `SELECT format('%I.%I', n.nspname, p.proname), oidvectortypes(p.proargtypes),
+			pg_get_function_arguments(p.oid), COALESCE(pg_get_function_result(p.oid), ''), pg_get_functiondef(p.oid),
+			ARRAY(SELECT DISTINCT format('%I.%I', tn.nspname, tc.relname)
+				FROM pg_type t
+				JOIN pg_class tc ON tc.oid = t.typrelid
+				JOIN pg_namespace tn ON tn.oid = tc.relnamespace
+				WHERE tc.relkind IN ('r', 'p')
+				AND (t.oid = ANY(COALESCE(p.proallargtypes, p.proargtypes::oid[]) || p.prorettype)
+					OR t.typarray = ANY(COALESCE(p.proallargtypes, p.proargtypes::oid[]) || p.prorettype)))
+		FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE p.prokind IN ('f', 'p') AND ` + userNamespace + `
+		AND ` + fmt.Sprintf(notExtensionMember, "pg_proc", "p.oid") + `
+		ORDER BY n.nspname, p.proname, 2`
+	return i.query(q, func(rows *sql.Rows) error {
+		var fn Function
+		if err := rows.Scan(&fn.Name, &fn.Arguments, &fn.Parameters, &fn.Result, &fn.Definition, pq.Array(&fn.DependsOn)); err != nil {
+			return err
+		}
+		s.Functions = append(s.Functions, fn)
+		return nil
+	})
+}
+
+// tables reads plain and partitioned tables, and partitions with their
+// parent and bound.
+func (i *inspector) tables(s *Schema) error {
+	q := `SELECT c.oid, format('%I.%I', n.nspname, c.relname),
+			CASE WHEN c.relkind = 'p' THEN pg_get_partkeydef(c.oid) ELSE '' END,
+			COALESCE((SELECT format('%I.%I', pn.nspname, pc.relname)
+				FROM pg_inherits inh
+				JOIN pg_class pc ON pc.oid = inh.inhparent
+				JOIN pg_namespace pn ON pn.oid = pc.relnamespace
+				WHERE inh.inhrelid = c.oid AND c.relispartition), ''),
+			CASE WHEN c.relispartition THEN pg_get_expr(c.relpartbound, c.oid) ELSE '' END
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND ` + userNamespace + `
+		AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "c.oid") + `
+		AND c.oid <> ALL($1::oid[])
+		ORDER BY n.nspname, c.relname`
+	var oids []int64
+	err := i.query(q, func(rows *sql.Rows) error {
+		var oid int64
+		var table Table
+		if err := rows.Scan(&oid, &table.Name, &table.PartitionBy, &table.PartitionOf, &table.PartitionBound); err != nil {
+			return err
+		}
+		oids = append(oids, oid)
+		s.Tables = append(s.Tables, table)
+		return nil
+	}, i.excluded)
+	if err != nil {
+		return err
+	}
+
+	for n, oid := range oids {
+		table := &s.Tables[n]
+		for _, fn := range []func(int64, *Table) error{i.columns, i.constraints, i.indexes, i.triggers} {
+			if err := fn(oid, table); err != nil {
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (i *inspector) columns(oid int64, t *Table) error {
+	q := `SELECT quote_ident(a.attname), format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			COALESCE(pg_get_expr(ad.adbin, ad.adrelid), ''), a.attidentity::text, a.attgenerated::text
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+		WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`
+	return i.query(q, func(rows *sql.Rows) error {
+		var col Column
+		var expr, identity, generated string
+		if err := rows.Scan(&col.Name, &col.Type, &col.NotNull, &expr, &identity, &generated); err != nil {
+			return err
+		}
+		switch {
+		case generated == "s":
+			col.Generated = expr
+		case identity == "a":
+			col.Identity = "always"
+		case identity == "d":
+			col.Identity = "by default"
+		default:
+			col.Default = expr
+		}
+		t.Columns = append(t.Columns, col)
+		return nil
+	}, oid)
+}
+
+// constraints leaves out the constraints a partition inherits from its
+// parent, which attaching it creates.
+func (i *inspector) constraints(oid int64, t *Table) error {
+	q := `SELECT quote_ident(conname), contype::text, pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE conrelid = $1 AND contype IN ('p', 'u', 'c', 'f', 'x')
+		AND conislocal AND conparentid = 0
+		ORDER BY contype <> 'p', conname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var c Constraint
+		if err := rows.Scan(&c.Name, &c.Type, &c.Definition); err != nil {
+			return err
+		}
+		t.Constraints = append(t.Constraints, c)
+		return nil
+	}, oid)
+}
+
+// indexes leaves out the indexes backing primary key, unique and exclusion
+// constraints, which create them, and the partitions of the indexes of a
+// partitioned table, which the parent index creates.
+func (i *inspector) indexes(oid int64, t *Table) error {
+	q := `SELECT quote_ident(ic.relname), pg_get_indexdef(x.indexrelid)
+		FROM pg_index x JOIN pg_class ic ON ic.oid = x.indexrelid
+		WHERE x.indrelid = $1
+		AND NOT EXISTS (SELECT 1 FROM pg_inherits inh WHERE inh.inhrelid = x.indexrelid)
+		AND NOT EXISTS (SELECT 1 FROM pg_constraint c
+			WHERE c.conrelid = x.indrelid AND c.conindid = x.indexrelid AND c.contype IN ('p', 'u', 'x'))
+		ORDER BY ic.relname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var idx Index
+		if err := rows.Scan(&idx.Name, &idx.Definition); err != nil {
+			return err
+		}
+		t.Indexes = append(t.Indexes, idx)
+		return nil
+	}, oid)
+}
+
+// triggers leaves out the clones of the triggers of a partitioned table on
+// its partitions.
+func (i *inspector) triggers(oid int64, t *Table) error {
+	q := `SELECT quote_ident(tg.tgname), pg_get_triggerdef(tg.oid)
+		FROM pg_trigger tg
+		WHERE tg.tgrelid = $1 AND NOT tg.tgisinternal
+		AND NOT EXISTS (SELECT 1 FROM pg_depend d
+			WHERE d.classid = 'pg_trigger'::regclass AND d.objid = tg.oid AND d.deptype IN ('P', 'S'))
+		ORDER BY tg.tgname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var trigger Trigger
+		if err := rows.Scan(&trigger.Name, &trigger.Definition); err != nil {
+			return err
+		}
+		t.Triggers = append(t.Triggers, trigger)
+		return nil
+	}, oid)
+}
+
+func (i *inspector) views(s *Schema) error {
+	q := `SELECT format('%I.%I', n.nspname, c.relname), c.relkind = 'm', pg_get_viewdef(c.oid),
+			ARRAY(SELECT DISTINCT format('%I.%I', dn.nspname, dc.relname)
+				FROM pg_rewrite rw
+				JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = rw.oid AND d.refclassid = 'pg_class'::regclass
+				JOIN pg_class dc ON dc.oid = d.refobjid
+				JOIN pg_namespace dn ON dn.oid = dc.relnamespace
+				WHERE rw.ev_class = c.oid AND dc.oid <> c.oid AND dc.relkind IN ('v', 'm'))
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('v', 'm') AND ` + userNamespace + `
+		AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "c.oid") + `
+		ORDER BY n.nspname, c.relname`
+	return i.query(q, func(rows *sql.Rows) error {
+		var view View
+		if err := rows.Scan(&view.Name, &view.Materialized, &view.Definition, pq.Array(&view.DependsOn)); err != nil {
+			return err
+		}
+		s.Views = append(s.Views, view)
+		return nil
+	})
+}
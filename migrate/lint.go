@@ -0,0 +1,309 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// directiveLintIgnore suppresses lint rules for the statement that follows
+// it or contains it: "-- migrate:lint-ignore ML002" or "ML001,ML003".
+const directiveLintIgnore = "lint-ignore"
+
+// Lint severities. Findings of SeverityError fail the lint command.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// LintRule is a pattern the linter flags.
+type LintRule struct {
+	ID          string
+	Severity    string
+	Description string
+}
+
+// Lint rules, each about a statement holding an ACCESS EXCLUSIVE or similar
+// lock on a table for longer than needed. Tables created in the same file
+// are exempt, since nothing can use them yet.
+var (
+	RuleVolatileDefault = LintRule{"ML001", SeverityError,
+		"Adding a column with a volatile default rewrites the table under an ACCESS EXCLUSIVE lock; add it without a default and backfill in batches"}
+	RuleIndexNotConcurrent = LintRule{"ML002", SeverityWarning,
+		"Creating an index without CONCURRENTLY blocks writes to the table until it is built; use CREATE INDEX CONCURRENTLY in a migration of its own, applied with up --version outside a transaction"}
+	RuleColumnTypeChange = LintRule{"ML003", SeverityError,
+		"Changing the type of a column usually rewrites the table under an ACCESS EXCLUSIVE lock; add a new column and backfill it instead"}
+	RuleForeignKeyValidated = LintRule{"ML004", SeverityWarning,
+		"Adding a foreign key validates every row while locking both tables; add it NOT VALID and VALIDATE CONSTRAINT in a later migration"}
+)
+
+// LintRules lists every rule, by ID.
+var LintRules = []LintRule{RuleVolatileDefault, RuleIndexNotConcurrent, RuleColumnTypeChange, RuleForeignKeyValidated}
+
+// LintFinding is a statement matching a lint rule.
+type LintFinding struct {
+	Rule    LintRule
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s %s: %s", f.File, f.Line, f.Column, f.Rule.Severity, f.Rule.ID, f.Message)
+}
+
+// Lint checks the SQL of files as they will be executed. Go migrations are
+// skipped.
+func (m *Migration) Lint(files []MigrationFile) ([]LintFinding, error) {
+	var findings []LintFinding
+	for _, file := range files {
+		if file.IsGo() {
+			continue
+		}
+		content, sm, err := m.renderSource(file)
+		if err != nil {
+			return nil, err
+		}
+		fileFindings, err := lintContent(m.dialect(), file.Path, content, sm)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, fileFindings...)
+	}
+	return findings, nil
+}
+
+const identifier = `(?:"[^"]*"|[A-Z_][A-Z0-9_$]*)`
+const qualifiedIdentifier = identifier + `(?:\.` + identifier + `)?`
+
+var (
+	createTablePattern = regexp.MustCompile(`^CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(` + qualifiedIdentifier + `)`)
+	createIndexPattern = regexp.MustCompile(`^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:` + identifier + `\s+)?ON\s+(?:ONLY\s+)?(` + qualifiedIdentifier + `)`)
+	alterTablePattern  = regexp.MustCompile(`^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(` + qualifiedIdentifier + `)\s+`)
+
+	addColumnPattern       = regexp.MustCompile(`^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(` + identifier + `)`)
+	volatileDefault        = regexp.MustCompile(`\bDEFAULT\s+.*\b(RANDOM|GEN_RANDOM_UUID|UUID_GENERATE_V1|UUID_GENERATE_V4|CLOCK_TIMESTAMP|TIMEOFDAY|NEXTVAL|TXID_CURRENT)\s*\(`)
+	alterColumnTypePattern = regexp.MustCompile(`^ALTER\s+(?:COLUMN\s+)?(` + identifier + `)\s+(?:SET\s+DATA\s+)?TYPE\b`)
+	foreignKeyPattern      = regexp.MustCompile(`^ADD\s+(?:CONSTRAINT\s+` + identifier + `\s+)?FOREIGN\s+KEY\b`)
+	notValidPattern        = regexp.MustCompile(`\bNOT\s+VALID\b`)
+)
+
+// constraintKeywords start the constraint form of ALTER TABLE ... ADD.
+var constraintKeywords = map[string]bool{"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "FOREIGN": true, "CHECK": true, "EXCLUDE": true}
+
+// lintContent checks the statements of one file, reporting their positions
+// through sm.
+func lintContent(dialect string, path string, content string, sm sourceMap) ([]LintFinding, error) {
+	statements, err := SplitStatements(dialect, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split migration file %s: %w", path, err)
+	}
+	sm.attach(statements)
+	ignores := lintIgnores(content)
+
+	created := map[string]bool{}
+	var findings []LintFinding
+	previousEnd := 0
+	for _, stmt := range statements {
+		end := stmt.Line + strings.Count(stmt.SQL, "\n")
+		ignored := map[string]bool{}
+		for line, rules := range ignores {
+			if line > previousEnd && line <= end {
+				for _, rule := range rules {
+					ignored[rule] = true
+				}
+			}
+		}
+		previousEnd = end
+
+		for _, f := range lintStatement(stmt, created) {
+			if ignored[f.Rule.ID] || ignored["all"] {
+				continue
+			}
+			f.File, f.Line, f.Column = stmt.origin(path, f.Line, f.Column)
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// lintIgnores returns the rule IDs of each lint-ignore directive, by line.
+func lintIgnores(content string) map[int][]string {
+	ignores := map[int][]string{}
+	for _, d := range directives(content) {
+		if d.name == directiveLintIgnore {
+			ignores[d.line] = strings.FieldsFunc(d.value, func(r rune) bool { return r == ',' || r == ' ' })
+		}
+	}
+	return ignores
+}
+
+// lintStatement checks one statement. created holds the tables created by
+// the previous statements of the file and is updated.
+func lintStatement(stmt Statement, created map[string]bool) []LintFinding {
+	sql := strings.ToUpper(blankLiterals(stmt.SQL))
+	var findings []LintFinding
+	report := func(rule LintRule, offset int, message string) {
+		line, column := stmt.locate(utf8.RuneCountInString(sql[:offset]) + 1)
+		findings = append(findings, LintFinding{Rule: rule, Line: line, Column: column, Message: message})
+	}
+
+	if match := createTablePattern.FindStringSubmatch(sql); match != nil {
+		created[tableKey(match[1])] = true
+		return nil
+	}
+
+	if match := createIndexPattern.FindStringSubmatchIndex(sql); match != nil {
+		table := sql[match[4]:match[5]]
+		if match[2] < 0 && !created[tableKey(table)] {
+			report(RuleIndexNotConcurrent, 0, fmt.Sprintf("index on %s is created without CONCURRENTLY", strings.ToLower(table)))
+		}
+		return findings
+	}
+
+	match := alterTablePattern.FindStringSubmatchIndex(sql)
+	if match == nil {
+		return nil
+	}
+	table := sql[match[2]:match[3]]
+	if created[tableKey(table)] {
+		return nil
+	}
+	for _, action := range splitTopLevel(sql, match[1]) {
+		text := sql[action.start:action.end]
+		if column := addColumnPattern.FindStringSubmatch(text); column != nil && !constraintKeywords[column[1]] {
+			if v := volatileDefault.FindStringSubmatchIndex(text); v != nil {
+				report(RuleVolatileDefault, action.start+v[0],
+					fmt.Sprintf("column %s of %s gets the volatile default %s()", strings.ToLower(column[1]), strings.ToLower(table), strings.ToLower(text[v[2]:v[3]])))
+			}
+		}
+		if column := alterColumnTypePattern.FindStringSubmatch(text); column != nil {
+			report(RuleColumnTypeChange, action.start, fmt.Sprintf("type of column %s of %s is changed", strings.ToLower(column[1]), strings.ToLower(table)))
+		}
+		if foreignKeyPattern.MatchString(text) && !notValidPattern.MatchString(text) {
+			report(RuleForeignKeyValidated, action.start, fmt.Sprintf("foreign key on %s is added without NOT VALID", strings.ToLower(table)))
+		}
+	}
+	return findings
+}
+
+// tableKey identifies a table by its unqualified name, so that "users" and
+// "public.users" match.
+func tableKey(name string) string {
+	_, name = splitQualified(name)
+	return strings.Trim(name, `"`)
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+// splitTopLevel splits sql from offset at the commas outside parentheses,
+// returning the trimmed actions of an ALTER TABLE.
+func splitTopLevel(sql string, offset int) []span {
+	var spans []span
+	depth, start := 0, offset
+	add := func(end int) {
+		for start < end && (sql[start] == ' ' || sql[start] == '\n' || sql[start] == '\t' || sql[start] == '\r') {
+			start++
+		}
+		if start < end {
+			spans = append(spans, span{start, end})
+		}
+	}
+	for i := offset; i < len(sql); i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				add(i)
+				start = i + 1
+			}
+		}
+	}
+	add(len(sql))
+	return spans
+}
+
+// blankLiterals replaces comments, string literals and dollar-quoted bodies
+// with spaces, keeping newlines and byte offsets, so that patterns only match
+// SQL keywords.
+func blankLiterals(sql string) string {
+	out := []byte(sql)
+	for _, s := range literalSpans(sql, true) {
+		for i := s.start; i < s.end && i < len(out); i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+	return string(out)
+}
+
+// literalSpans returns the byte ranges of the block comments, the contents of
+// string literals and dollar-quoted bodies of sql and, when lineComments is
+// set, of its line comments.
+func literalSpans(sql string, lineComments bool) []span {
+	var spans []span
+	for i := 0; i < len(sql); {
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			if lineComments {
+				spans = append(spans, span{i, i + end})
+			}
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 4
+			}
+			spans = append(spans, span{i, i + end + 4})
+			i += end + 4
+		case sql[i] == '\'':
+			j := i + 1
+			for j < len(sql) {
+				if sql[j] == '\\' && i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') {
+					j += 2
+					continue
+				}
+				if sql[j] == '\'' {
+					if j+1 < len(sql) && sql[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			spans = append(spans, span{i + 1, j})
+			i = j + 1
+		case sql[i] == '$':
+			tag := dollarTagPattern.FindString(sql[i:])
+			if tag == "" {
+				i++
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				end = len(sql) - i - len(tag)
+			}
+			spans = append(spans, span{i + len(tag), i + len(tag) + end})
+			i += len(tag) + end + len(tag)
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+var dollarTagPattern = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
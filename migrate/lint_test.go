@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintContent(t *testing.T) {
+	content := `CREATE TABLE accounts (id BIGINT PRIMARY KEY);
+CREATE INDEX accounts_id_idx ON accounts (id);
+
+ALTER TABLE users
+    ADD COLUMN token UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ALTER COLUMN name TYPE VARCHAR(200),
+    ADD CONSTRAINT users_account_fkey FOREIGN KEY (account_id) REFERENCES accounts (id);
+
+ALTER TABLE public.posts ADD CONSTRAINT posts_user_fkey FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID;
+CREATE INDEX CONCURRENTLY posts_user_idx ON posts (user_id);
+CREATE UNIQUE INDEX users_email_idx ON public.users (email);
+
+-- migrate:lint-ignore ML002
+CREATE INDEX users_name_idx ON users (name);
+COMMENT ON TABLE users IS 'CREATE INDEX x ON users (y)';
+CREATE FUNCTION f() RETURNS void AS $$ ALTER TABLE users ALTER COLUMN a TYPE text $$ LANGUAGE sql;
+-- migrate:lint-ignore ML001, ML003
+ALTER TABLE users ALTER COLUMN id SET DATA TYPE BIGINT;
+`
+	findings, err := lintContent("postgres", "0001_lint.up.sql", content, nil)
+	assert.NoError(t, err)
+
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	assert.Equal(t, []string{
+		"0001_lint.up.sql:5:36: error ML001: column token of users gets the volatile default gen_random_uuid()",
+		"0001_lint.up.sql:7:5: error ML003: type of column name of users is changed",
+		"0001_lint.up.sql:8:5: warning ML004: foreign key on users is added without NOT VALID",
+		"0001_lint.up.sql:12:1: warning ML002: index on public.users is created without CONCURRENTLY",
+	}, got)
+}
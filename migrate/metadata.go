@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/lib/pq"
+)
+
+// layoutMigrations upgrade the history table from one layout to the next:
+// entry i brings it to layout i+1. Each entry is a format string receiving
+// the quoted history table name. Released entries must never change; append
+// new ones instead.
+var layoutMigrations = []string{
+	// 1: the original table
+	`CREATE TABLE IF NOT EXISTS %[1]s (
+		version VARCHAR(255) PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// 2: batch numbers; rows applied before stay in batch 0
+	`ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS batch INTEGER NOT NULL DEFAULT 0`,
+	// 3: time zone aware timestamps; old values were written in the session time zone
+	`ALTER TABLE %[1]s
+		ALTER COLUMN applied_at TYPE TIMESTAMPTZ USING applied_at AT TIME ZONE current_setting('TimeZone'),
+		ALTER COLUMN applied_at SET DEFAULT now()`,
+	// 4: repeatable migrations share the table, told apart by kind; checksums detect changed files
+	`ALTER TABLE %[1]s
+		ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'versioned',
+		ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)`,
+}
+
+// LayoutVersion is the layout of the history table this version of the
+// package works with.
+var LayoutVersion = len(layoutMigrations)
+
+// upgradeLayout creates the history table or upgrades it to LayoutVersion.
+// The layout version is kept in a companion "<table>_meta" table. Everything
+// happens in one transaction holding an advisory lock, so concurrent runs
+// wait for each other instead of racing. A table without the companion
+// table was created before layouts were versioned and is upgraded from
+// scratch, which is safe because the first steps are idempotent.
+func (r *repository) upgradeLayout() (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("SELECT pg_advisory_xact_lock($1)", r.lockKey()); err != nil {
+		return fmt.Errorf("failed to lock migration table: %w", err)
+	}
+
+	if r.tableSchema != "" {
+		if _, err = tx.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pq.QuoteIdentifier(r.tableSchema))); err != nil {
+			return fmt.Errorf("failed to create schema %s: %w", r.tableSchema, err)
+		}
+	}
+
+	meta := r.metaTable()
+	if _, err = tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (layout_version INTEGER NOT NULL)", meta)); err != nil {
+		return fmt.Errorf("failed to create migration metadata table: %w", err)
+	}
+
+	current := 0
+	err = tx.QueryRow(fmt.Sprintf("SELECT layout_version FROM %s", meta)).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read migration table layout version: %w", err)
+	}
+	err = nil
+
+	if current > LayoutVersion {
+		return fmt.Errorf("migration table %s has layout version %d, newer than the supported %d; upgrade this tool", r.table, current, LayoutVersion)
+	}
+	if current == LayoutVersion {
+		return tx.Commit()
+	}
+
+	for i := current; i < LayoutVersion; i++ {
+		if _, err = tx.Exec(fmt.Sprintf(layoutMigrations[i], r.table)); err != nil {
+			return fmt.Errorf("failed to upgrade migration table to layout version %d: %w", i+1, err)
+		}
+	}
+
+	if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s", meta)); err != nil {
+		return fmt.Errorf("failed to update migration table layout version: %w", err)
+	}
+	if _, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (layout_version) VALUES ($1)", meta), LayoutVersion); err != nil {
+		return fmt.Errorf("failed to update migration table layout version: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// metaTable returns the quoted name of the table holding the layout version.
+func (r *repository) metaTable() string {
+	return qualifiedName(r.tableSchema, r.tableName+"_meta")
+}
+
+// lockKey derives the advisory lock key from the history table, so tools
+// sharing a database but using different tables don't block each other.
+func (r *repository) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("gooolib/migration:" + r.table))
+	return int64(h.Sum64())
+}
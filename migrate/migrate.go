@@ -1,13 +1,14 @@
 package migrate
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"path/filepath"
-	"sort"
-	"strings"
+	"time"
 
 	"github.com/gooolib/migration/config"
 )
@@ -19,6 +20,9 @@ type statusGetter interface {
 type schemaMigrationReader interface {
 	ListAppliedMigrations() ([]SchemaMigration, error)
 	IsMigrationApplied(version string) (bool, error)
+	GetLastBatch() (int, error)
+	ListBatchVersions(batch int) ([]string, error)
+	ListRepeatableMigrations() ([]SchemaMigration, error)
 }
 
 type schemaMigrationInitialzier interface {
@@ -26,11 +30,20 @@ type schemaMigrationInitialzier interface {
 }
 
 type schemaMigrationUpdater interface {
-	RecordMigration(tx *sql.Tx, version string) error
+	RecordMigration(tx *sql.Tx, version string, batch int, checksum string) error
+	RecordRepeatable(tx *sql.Tx, name string, batch int, checksum string) error
+	RemoveRepeatableRecords(tx *sql.Tx) error
 	RemoveMigrationRecord(tx *sql.Tx, version string) error
 	ResetMigrations() error
 }
 
+type seedStore interface {
+	CreateSeedTable() error
+	ListAppliedSeeds(tx *sql.Tx) (map[string]bool, error)
+	RecordSeed(tx *sql.Tx, name string, checksum string) error
+	RemoveSeedRecords(tx *sql.Tx) error
+}
+
 // FIXME: interface has too many methods, consider splitting it
 type repositoryInterface interface {
 	DB() *sql.DB
@@ -41,12 +54,49 @@ type Migration struct {
 	CurrentVersion string
 	UpFiles        []MigrationFile
 	DownFiles      []MigrationFile
-	repo           repositoryInterface
-	statusGetter   statusGetter
-	schemaReader   schemaMigrationReader
-	schemaUpdater  schemaMigrationUpdater
-	schemaInit     schemaMigrationInitialzier
-	config         *config.Config
+	// RepeatableFiles are re-applied after the versioned migrations whenever
+	// their checksum changes, in name order.
+	RepeatableFiles []MigrationFile
+	repo            repositoryInterface
+	statusGetter    statusGetter
+	schemaReader    schemaMigrationReader
+	schemaUpdater   schemaMigrationUpdater
+	schemaInit      schemaMigrationInitialzier
+	seedStore       seedStore
+	inspector       schemaInspector
+	activity        activityReader
+	config          *config.Config
+	ctx             context.Context
+	logger          *log.Logger
+	vars            map[string]string
+}
+
+// SetContext sets the context passed to Go migrations and bounding the
+// transactions, statements and catalog queries of the migrations. Cancelling
+// it stops the running statement and rolls its transaction back.
+func (m *Migration) SetContext(ctx context.Context) {
+	m.ctx = ctx
+}
+
+func (m *Migration) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// SetLogger sets where progress messages are written. It defaults to the
+// standard logger.
+func (m *Migration) SetLogger(logger *log.Logger) {
+	m.logger = logger
+}
+
+func (m *Migration) logf(format string, args ...any) {
+	if m.logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	m.logger.Printf(format, args...)
 }
 
 func (m *Migration) Config() *config.Config {
@@ -61,108 +111,257 @@ func (m *Migration) Versions() []string {
 	return versions
 }
 
-func (m *Migration) Up() error {
-	tx, err := m.repo.DB().Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+// Up applies the pending migrations, then the changed repeatable ones, as
+// one batch in one transaction.
+func (m *Migration) Up(opts ...PreflightOption) error {
+	_, err := m.runPending(true, opts)
+	return err
+}
+
+// fileTiming is how long applying a migration took.
+type fileTiming struct {
+	file MigrationFile
+	took time.Duration
+}
+
+// runPending applies the pending migrations in one transaction, retried on
+// lock timeouts, and commits it, or rolls it back when commit is false. It
+// returns how long each migration of the last attempt took.
+func (m *Migration) runPending(commit bool, opts []PreflightOption) ([]fileTiming, error) {
+	if err := m.preflight(opts); err != nil {
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
 
+	var timings []fileTiming
+	err := m.retryLocks("the pending migrations", func() error {
+		return m.transaction(commit, func(tx *sql.Tx) (err error) {
+			timings, err = m.applyPending(tx)
+			return err
+		})
+	})
+	return timings, err
+}
+
+// applyPending runs the pending versioned migrations and then the changed
+// repeatable ones inside tx, as one batch, returning how long each one took.
+func (m *Migration) applyPending(tx *sql.Tx) ([]fileTiming, error) {
 	currentVersion, err := m.statusGetter.GetCurrentVersion()
 	if err != nil {
-		return fmt.Errorf("failed to get current version: %w", err)
+		return nil, fmt.Errorf("failed to get current version: %w", err)
+	}
+
+	batch, err := m.nextBatch()
+	if err != nil {
+		return nil, err
 	}
 
+	var timings []fileTiming
 	for _, file := range m.UpFiles {
 		applied := file.Version() <= currentVersion
 		if applied {
 			continue
 		}
-		if err := m.executeFile(tx, file); err != nil {
-			return err
+		started := time.Now()
+		if err := m.applyUp(tx, file, batch); err != nil {
+			return timings, err
 		}
+		timings = append(timings, fileTiming{file, time.Since(started)})
+	}
 
-		if err := m.schemaUpdater.RecordMigration(tx, file.Version()); err != nil {
-			return fmt.Errorf("failed to record migration: %w", err)
-		}
+	repeatables, err := m.applyRepeatables(tx, batch)
+	return append(timings, repeatables...), err
+}
+
+// applyUp runs an up file inside tx, or outside a transaction when tx is
+// nil, and records it in batch.
+func (m *Migration) applyUp(tx *sql.Tx, file MigrationFile, batch int) error {
+	checksum, err := m.checksum(file)
+	if err != nil {
+		return err
+	}
+	squashed, err := m.squashedVersions(file)
+	if err != nil {
+		return err
+	}
+	if err := m.checkBaseline(file, squashed); err != nil {
+		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := m.runFile(tx, file); err != nil {
+		return err
 	}
 
+	if err := m.schemaUpdater.RecordMigration(tx, file.Version(), batch, checksum); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	for _, version := range squashed {
+		if version == file.Version() {
+			continue
+		}
+		if err := m.schemaUpdater.RecordMigration(tx, version, batch, ""); err != nil {
+			return fmt.Errorf("failed to record squashed migration %s: %w", version, err)
+		}
+	}
 	return nil
 }
 
-func (m *Migration) Down() error {
-	tx, err := m.repo.DB().Begin()
+// PendingFiles returns the up files Up would apply, in order, followed by
+// the repeatable migrations that are new or changed.
+func (m *Migration) PendingFiles() ([]MigrationFile, error) {
+	currentVersion, err := m.statusGetter.GetCurrentVersion()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to get current version: %w", err)
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
+
+	var pending []MigrationFile
+	for _, file := range m.UpFiles {
+		if file.Version() > currentVersion {
+			pending = append(pending, file)
 		}
-	}()
+	}
 
-	file := m.DownFiles[len(m.DownFiles)-1]
-	if err := m.executeFile(tx, file); err != nil {
-		return err
+	repeatables, err := m.repeatableStatuses()
+	if err != nil {
+		return nil, err
 	}
-	if err := m.schemaUpdater.RemoveMigrationRecord(tx, file.Version()); err != nil {
-		return fmt.Errorf("failed to remove migration record: %w", err)
+	for i, status := range repeatables {
+		if status.Status != "up" {
+			pending = append(pending, m.RepeatableFiles[i])
+		}
 	}
+	return pending, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+// applyRepeatables runs, in name order, the repeatable migrations that were
+// never applied or whose checksum changed since they last were, returning
+// how long each one took.
+func (m *Migration) applyRepeatables(tx *sql.Tx, batch int) ([]fileTiming, error) {
+	if len(m.RepeatableFiles) == 0 {
+		return nil, nil
 	}
 
-	return nil
-}
-
-func (m *Migration) DownAll() error {
-	tx, err := m.repo.DB().Begin()
+	applied, err := m.schemaReader.ListRepeatableMigrations()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to list applied repeatable migrations: %w", err)
 	}
-	defer func() {
+	checksums := make(map[string]string, len(applied))
+	for _, migration := range applied {
+		checksums[migration.Version] = migration.Checksum
+	}
+
+	var timings []fileTiming
+	for _, file := range m.RepeatableFiles {
+		checksum, err := m.checksum(file)
 		if err != nil {
-			tx.Rollback()
+			return timings, err
 		}
-	}()
+		if checksums[file.Name()] == checksum {
+			continue
+		}
+		started := time.Now()
+		if err := m.runFile(tx, file); err != nil {
+			return timings, err
+		}
+		if err := m.schemaUpdater.RecordRepeatable(tx, file.Name(), batch, checksum); err != nil {
+			return timings, fmt.Errorf("failed to record repeatable migration: %w", err)
+		}
+		timings = append(timings, fileTiming{file, time.Since(started)})
+	}
+	return timings, nil
+}
 
+// Down reverts the highest applied version.
+func (m *Migration) Down() error {
 	currentVersion, err := m.statusGetter.GetCurrentVersion()
 	if err != nil {
 		return fmt.Errorf("failed to get current version: %w", err)
 	}
+	if currentVersion == "" {
+		return fmt.Errorf("no applied migration to roll back")
+	}
 
-	for i := len(m.DownFiles) - 1; i >= 0; i-- {
-		file := m.DownFiles[i]
-		applied := file.Version() > currentVersion
-		if applied {
-			continue
-		}
+	return m.rollback([]string{currentVersion})
+}
 
-		if err := m.executeFile(tx, file); err != nil {
-			return err
-		}
+// RollbackBatch reverts every migration applied by the most recent up
+// invocation, newest version first, in a single transaction. The versions
+// applied before batches were recorded, in batch 0, and those recorded by
+// schema load are never rolled back as a batch.
+func (m *Migration) RollbackBatch() error {
+	batch, err := m.schemaReader.GetLastBatch()
+	if err != nil {
+		return fmt.Errorf("failed to get last batch: %w", err)
+	}
+	if batch <= 0 {
+		m.logf("No batch to roll back")
+		return nil
+	}
 
-		if err := m.schemaUpdater.RemoveMigrationRecord(tx, file.Version()); err != nil {
-			return fmt.Errorf("failed to remove migration record: %w", err)
-		}
+	versions, err := m.schemaReader.ListBatchVersions(batch)
+	if err != nil {
+		return fmt.Errorf("failed to list migrations in batch %d: %w", batch, err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := m.rollback(versions); err != nil {
+		return err
 	}
 
+	m.logf("Rolled back batch %d (%d migrations)", batch, len(versions))
 	return nil
 }
 
+// DownAll reverts every applied migration, newest version first.
+func (m *Migration) DownAll() error {
+	applied, err := m.schemaReader.ListAppliedMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	versions := make([]string, 0, len(applied))
+	for i := len(applied) - 1; i >= 0; i-- {
+		versions = append(versions, applied[i].Version)
+	}
+
+	if err := m.rollback(versions); err != nil {
+		return err
+	}
+
+	// The objects repeatable migrations define are usually gone with the
+	// schema they depend on, so they are all applied again by the next up.
+	if err := m.schemaUpdater.RemoveRepeatableRecords(nil); err != nil {
+		return fmt.Errorf("failed to remove repeatable migration records: %w", err)
+	}
+	return nil
+}
+
+// rollback runs the down files of the given versions in order within one
+// transaction. Every down file is resolved before anything is executed, so an
+// irreversible migration stops the rollback without touching the database.
+func (m *Migration) rollback(versions []string) error {
+	files := make([]MigrationFile, 0, len(versions))
+	for _, version := range versions {
+		file, err := m.FindDownFile(version)
+		if err != nil {
+			return err
+		}
+		files = append(files, *file)
+	}
+
+	return m.retryLocks("the rollback", func() error {
+		return m.inTransaction(func(tx *sql.Tx) error {
+			for _, file := range files {
+				if err := m.runFile(tx, file); err != nil {
+					return err
+				}
+				if err := m.schemaUpdater.RemoveMigrationRecord(tx, file.Version()); err != nil {
+					return fmt.Errorf("failed to remove migration record: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
 func (m *Migration) SoftReset() error {
 	if err := m.DownAll(); err != nil {
 		return fmt.Errorf("failed to reset migrations: %w", err)
@@ -179,51 +378,153 @@ func (m *Migration) HardReset() error {
 	return m.schemaUpdater.ResetMigrations()
 }
 
+// execer is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// executeFile runs the statements of file one by one, inside tx when it is
+// not nil and directly on the database otherwise.
 func (m *Migration) executeFile(tx *sql.Tx, file MigrationFile) error {
-	content, err := ioutil.ReadFile(file.Path)
+	if file.IsGo() {
+		return m.executeGoMigration(tx, file)
+	}
+
+	statements, err := m.readStatements(file)
 	if err != nil {
-		return fmt.Errorf("failed to read migration file %s: %w", file.Path, err)
+		return err
 	}
 
 	if tx == nil {
-		if m.repo.DB().Exec(string(content)); err != nil {
-			return fmt.Errorf("failed to execute migration file %s: %w", file.Path, err)
+		return m.execStatements(m.repo.DB(), file, statements)
+	}
+	return m.execStatements(tx, file, statements)
+}
+
+// execStatements runs the statements of file one by one on db.
+func (m *Migration) execStatements(db execer, file MigrationFile, statements []Statement) error {
+	started := time.Now()
+	for i, stmt := range statements {
+		stmtStarted := time.Now()
+		if _, err := db.ExecContext(m.context(), stmt.SQL); err != nil {
+			return newMigrationError(file, i+1, stmt, err)
 		}
-		log.Printf("Successfully executed migration: %s", file.Path)
-	} else {
-		if _, err := tx.Exec(string(content)); err != nil {
-			return fmt.Errorf("failed to execute migration file %s: %w", file.Path, err)
+		source, line, _ := stmt.origin(file.Path, stmt.Line, stmt.Column)
+		if source == file.Path {
+			m.logf("  statement %d (line %d) took %s", i+1, line, time.Since(stmtStarted))
+		} else {
+			m.logf("  statement %d (%s line %d) took %s", i+1, source, line, time.Since(stmtStarted))
 		}
 	}
 
-	log.Printf("Successfully executed migration in transaction: %s", file.Path)
+	m.logf("Successfully executed migration: %s (%d statements, %s)", file.Path, len(statements), time.Since(started))
 	return nil
 }
 
-func (m *Migration) Load(path string) error {
-	files, err := filepath.Glob(filepath.Join(path, "*.sql"))
+// executeGoMigration runs a registered Go migration inside tx, or inside a
+// transaction of its own when tx is nil.
+func (m *Migration) executeGoMigration(tx *sql.Tx, file MigrationFile) (err error) {
+	started := time.Now()
+	if tx == nil {
+		tx, err = m.repo.DB().BeginTx(m.context(), nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+			if err = tx.Commit(); err != nil {
+				err = fmt.Errorf("failed to commit transaction: %w", err)
+			}
+		}()
+	}
+
+	if err := file.fn(m.context(), tx); err != nil {
+		return fmt.Errorf("go migration %s failed: %w", file.Path, err)
+	}
+
+	m.logf("Successfully executed migration: %s (%s)", file.Path, time.Since(started))
+	return nil
+}
+
+// readContent returns the SQL of file with its include directives expanded.
+func (m *Migration) readContent(file MigrationFile) (string, error) {
+	content, _, err := m.readSource(file)
+	return content, err
+}
+
+// readSource returns the SQL of file with its include directives expanded,
+// with the map of its lines back to file and the fragments.
+func (m *Migration) readSource(file MigrationFile) (string, sourceMap, error) {
+	content, err := ioutil.ReadFile(file.Path)
 	if err != nil {
-		return fmt.Errorf("failed to list migration files: %w", err)
+		return "", nil, fmt.Errorf("failed to read migration file %s: %w", file.Path, err)
 	}
+	return m.expandIncludes(string(content), []string{file.Path})
+}
 
-	sort.Strings(files)
+// readStatements returns the statements of file as they will be executed.
+// Their positions resolve to the files the statements were written in.
+func (m *Migration) readStatements(file MigrationFile) ([]Statement, error) {
+	content, sm, err := m.renderSource(file)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, file := range files {
-		fileName := filepath.Base(file)
-		if strings.Contains(fileName, "up") {
-			m.UpFiles = append(m.UpFiles, MigrationFile{
-				Path: file,
-				Kind: "up",
-			})
-		} else if strings.Contains(fileName, "down") {
-			m.DownFiles = append(m.DownFiles, MigrationFile{
-				Path: file,
-				Kind: "down",
-			})
-		} else {
-			return fmt.Errorf("invalid migration file name: %s, expected 'up' or 'down' in the name", fileName)
-		}
+	statements, err := SplitStatements(m.dialect(), content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split migration file %s: %w", file.Path, err)
 	}
+	sm.attach(statements)
+	return statements, nil
+}
+
+// checksum identifies the content of a SQL migration file, including the
+// fragments it includes. Go migrations have no checksum.
+func (m *Migration) checksum(file MigrationFile) (string, error) {
+	if file.IsGo() {
+		return "", nil
+	}
+	content, err := m.readContent(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// env returns the environment the configuration was loaded for.
+func (m *Migration) env() string {
+	if m.config == nil || m.config.Env == "" {
+		return config.DefaultEnv
+	}
+	return m.config.Env
+}
+
+func (m *Migration) dialect() string {
+	if m.config == nil {
+		return ""
+	}
+	return m.config.Database.Dialect
+}
+
+// Load reads the migration files in path. It fails with ValidationErrors when
+// any file in the directory doesn't pass Validate.
+func (m *Migration) Load(path string) error {
+	contents, err := scanDir(path)
+	if err != nil {
+		return err
+	}
+	if len(contents.problems) > 0 {
+		return contents.problems
+	}
+
+	m.UpFiles = contents.upFiles
+	m.DownFiles = contents.downFiles
+	m.RepeatableFiles = contents.repeatableFiles
 
 	version, err := m.statusGetter.GetCurrentVersion()
 	if err != nil {
@@ -235,20 +536,26 @@ func (m *Migration) Load(path string) error {
 	return nil
 }
 
-func (m *Migration) RunSingleUp(file MigrationFile) error {
-	if err := m.executeFile(nil, file); err != nil {
+func (m *Migration) RunSingleUp(file MigrationFile, opts ...PreflightOption) error {
+	if err := m.preflight(opts); err != nil {
 		return err
 	}
 
-	if err := m.schemaUpdater.RecordMigration(nil, file.Version()); err != nil {
-		return fmt.Errorf("failed to record migration: %w", err)
+	batch, err := m.nextBatch()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return m.retryLocks(file.Path, func() error {
+		return m.applyUp(nil, file, batch)
+	})
 }
 
 func (m *Migration) RunSingleDown(file MigrationFile) error {
-	if err := m.executeFile(nil, file); err != nil {
+	err := m.retryLocks(file.Path, func() error {
+		return m.runFile(nil, file)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -259,10 +566,52 @@ func (m *Migration) RunSingleDown(file MigrationFile) error {
 	return nil
 }
 
+// inTransaction calls fn inside a transaction, committed when fn succeeds
+// and rolled back otherwise.
+func (m *Migration) inTransaction(fn func(tx *sql.Tx) error) error {
+	return m.transaction(true, fn)
+}
+
+// transaction calls fn inside a transaction, committed when fn succeeds and
+// commit is set, and rolled back otherwise.
+func (m *Migration) transaction(commit bool, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := m.repo.DB().BeginTx(m.context(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if !commit {
+		if err = tx.Rollback(); err != nil {
+			return fmt.Errorf("failed to roll back transaction: %w", err)
+		}
+		return nil
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (m *Migration) nextBatch() (int, error) {
+	batch, err := m.schemaReader.GetLastBatch()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last batch: %w", err)
+	}
+	return batch + 1, nil
+}
+
 func (m *Migration) GetCurrentVersion() string {
 	version, err := m.statusGetter.GetCurrentVersion()
 	if err != nil {
-		log.Printf("Error getting current version: %v", err)
+		m.logf("Error getting current version: %v", err)
 		return ""
 	}
 	return version
@@ -272,8 +621,36 @@ func (m *Migration) CreateMigrationTable() error {
 	return m.schemaInit.CreateMigrationTable()
 }
 
-func NewMigration(config *config.Config) (*Migration, error) {
-	repo, err := newRepository(config.Database.DSN())
+// Option customizes NewMigration.
+type Option func(*options)
+
+type options struct {
+	tableSchema string
+	table       string
+	seedTable   string
+}
+
+// WithTable stores the migration history in schema.table instead of the
+// table configured in config.CmdConfig. An empty schema means the table is
+// resolved through the search_path. The schema is created when missing.
+func WithTable(schema string, table string) Option {
+	return func(o *options) {
+		o.tableSchema = schema
+		o.table = table
+	}
+}
+
+func NewMigration(config *config.Config, opts ...Option) (*Migration, error) {
+	o := &options{
+		tableSchema: config.Command.TableSchema,
+		table:       config.Command.Table,
+		seedTable:   config.Command.SeedTable,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	repo, err := newRepository(config.Database.DSN(), o.tableSchema, o.table, o.seedTable)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create repository: %w", err)
 	}
@@ -284,6 +661,9 @@ func NewMigration(config *config.Config) (*Migration, error) {
 		schemaReader:  repo,
 		schemaUpdater: repo,
 		schemaInit:    repo,
+		seedStore:     repo,
+		inspector:     repo,
+		activity:      repo,
 		config:        config,
 	}
 
@@ -294,14 +674,29 @@ func NewMigration(config *config.Config) (*Migration, error) {
 	return migration, nil
 }
 
+// NewOfflineMigration returns a migration holding config without connecting
+// to the database, for commands that only read the migration files, such as
+// validate. Methods that query the database must not be called on it.
+func NewOfflineMigration(config *config.Config) *Migration {
+	return &Migration{config: config}
+}
+
+// Status reports every migration as "up", "pending", or "modified" when an
+// applied SQL migration or one of its included fragments changed since.
 func (m *Migration) Status() ([]SchemaMigrationStatus, error) {
 	applied, err := m.schemaReader.ListAppliedMigrations()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
 	}
 
-	statuses := make([]SchemaMigrationStatus, len(m.Versions()))
-	for i, version := range m.Versions() {
+	statuses := make([]SchemaMigrationStatus, len(m.UpFiles))
+	for i, file := range m.UpFiles {
+		version := file.Version()
+		source := "sql"
+		if file.IsGo() {
+			source = "go"
+		}
+
 		var found *SchemaMigration
 		for _, v := range applied {
 			if v.Version == version {
@@ -311,20 +706,92 @@ func (m *Migration) Status() ([]SchemaMigrationStatus, error) {
 		}
 
 		if found != nil {
+			status := "up"
+			if found.Checksum != "" {
+				sum, err := m.checksum(file)
+				if err != nil {
+					return nil, err
+				}
+				if sum != found.Checksum {
+					status = "modified"
+					// A baseline differs from the files it replaced by design.
+					squashed, err := m.squashedVersions(file)
+					if err != nil {
+						return nil, err
+					}
+					if len(squashed) > 0 {
+						status = "up"
+					}
+				}
+			}
 			statuses[i] = SchemaMigrationStatus{
 				Version:   version,
+				Name:      file.Name(),
+				Source:    source,
 				AppliedAt: &found.AppliedAt,
-				Status:    "up",
+				Batch:     found.Batch,
+				Status:    status,
 			}
 		} else {
 			statuses[i] = SchemaMigrationStatus{
 				Version:   version,
+				Name:      file.Name(),
+				Source:    source,
 				AppliedAt: nil,
 				Status:    "pending",
 			}
 		}
 	}
 
+	repeatables, err := m.repeatableStatuses()
+	if err != nil {
+		return nil, err
+	}
+	statuses = append(statuses, repeatables...)
+
+	return statuses, nil
+}
+
+// repeatableStatuses reports each repeatable migration as "up", "pending"
+// when it was never applied or "outdated" when it changed since.
+func (m *Migration) repeatableStatuses() ([]SchemaMigrationStatus, error) {
+	if len(m.RepeatableFiles) == 0 {
+		return nil, nil
+	}
+
+	applied, err := m.schemaReader.ListRepeatableMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied repeatable migrations: %w", err)
+	}
+
+	statuses := make([]SchemaMigrationStatus, 0, len(m.RepeatableFiles))
+	for _, file := range m.RepeatableFiles {
+		status := SchemaMigrationStatus{
+			Version: "R",
+			Name:    file.Name(),
+			Source:  "sql",
+			Status:  "pending",
+		}
+
+		for _, migration := range applied {
+			if migration.Version != file.Name() {
+				continue
+			}
+			checksum, err := m.checksum(file)
+			if err != nil {
+				return nil, err
+			}
+			status.AppliedAt = &migration.AppliedAt
+			status.Batch = migration.Batch
+			status.Status = "up"
+			if migration.Checksum != checksum {
+				status.Status = "outdated"
+			}
+			break
+		}
+
+		statuses = append(statuses, status)
+	}
 	return statuses, nil
 }
 
@@ -350,6 +817,38 @@ func (m *Migration) IsMigrationApplied(version string) (bool, error) {
 	return m.schemaReader.IsMigrationApplied(version)
 }
 
+// FindDownFile returns the down file that reverts the given version. It returns
+// an *IrreversibleMigrationError when the migration has no down file or either
+// of its files carries the "-- migrate:irreversible" directive.
+func (m *Migration) FindDownFile(version string) (*MigrationFile, error) {
+	up := m.FindFileByVersion(version, "up")
+	down := m.FindFileByVersion(version, "down")
+	if up == nil && down == nil {
+		return nil, fmt.Errorf("migration file with version %s not found", version)
+	}
+	if down == nil {
+		return nil, &IrreversibleMigrationError{Version: version, Reason: "no down file"}
+	}
+
+	for _, file := range []*MigrationFile{up, down} {
+		if file == nil || file.IsGo() {
+			continue
+		}
+		content, err := m.readContent(*file)
+		if err != nil {
+			return nil, err
+		}
+		if hasDirective(content, directiveIrreversible) {
+			return nil, &IrreversibleMigrationError{
+				Version: version,
+				Reason:  fmt.Sprintf("marked irreversible in %s", file.Path),
+			}
+		}
+	}
+
+	return down, nil
+}
+
 func findInFiles(files []MigrationFile, version string) *MigrationFile {
 	for _, file := range files {
 		if file.Version() == version {
@@ -1,12 +1,129 @@
 package migrate
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gooolib/migration/config"
 	"github.com/stretchr/testify/assert"
 )
 
+// writeMigrationFile writes a migration file named name in dir and returns
+// it, as a down file when the name says so.
+func writeMigrationFile(t *testing.T, dir string, name string, content string) MigrationFile {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	kind := "up"
+	if strings.Contains(name, ".down.") {
+		kind = "down"
+	}
+	return MigrationFile{Path: path, Kind: kind}
+}
+
+// recordingDB is a database/sql connector logging the transactions and
+// statements it receives. fail, when set, returns the error a statement
+// fails with.
+type recordingDB struct {
+	mu   sync.Mutex
+	log  []string
+	fail func(query string) error
+}
+
+func (d *recordingDB) record(entry string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, entry)
+}
+
+func (d *recordingDB) entries() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.log...)
+}
+
+func (d *recordingDB) Connect(context.Context) (driver.Conn, error) { return recordingConn{d}, nil }
+func (d *recordingDB) Driver() driver.Driver                        { return d }
+func (d *recordingDB) Open(string) (driver.Conn, error)             { return recordingConn{d}, nil }
+
+type recordingConn struct{ db *recordingDB }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c recordingConn) Close() error { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN")
+	return recordingTx(c), nil
+}
+func (c recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	if c.db.fail != nil {
+		if err := c.db.fail(query); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+type recordingTx struct{ db *recordingDB }
+
+func (tx recordingTx) Commit() error {
+	tx.db.record("COMMIT")
+	return nil
+}
+func (tx recordingTx) Rollback() error {
+	tx.db.record("ROLLBACK")
+	return nil
+}
+
+type recordingRepository struct{ db *sql.DB }
+
+func (r recordingRepository) DB() *sql.DB  { return r.db }
+func (r recordingRepository) Close() error { return r.db.Close() }
+
+// recordingUpdater logs the changes to the migration history in a
+// recordingDB.
+type recordingUpdater struct{ db *recordingDB }
+
+func (u recordingUpdater) RecordMigration(tx *sql.Tx, version string, batch int, checksum string) error {
+	u.db.record("record " + version)
+	return nil
+}
+func (u recordingUpdater) RecordRepeatable(tx *sql.Tx, name string, batch int, checksum string) error {
+	u.db.record("record " + name)
+	return nil
+}
+func (u recordingUpdater) RemoveRepeatableRecords(tx *sql.Tx) error { return nil }
+func (u recordingUpdater) RemoveMigrationRecord(tx *sql.Tx, version string) error {
+	u.db.record("remove " + version)
+	return nil
+}
+func (u recordingUpdater) ResetMigrations() error { return nil }
+
+// newRecordingMigration returns a Migration of files running against a
+// recordingDB with no migration applied.
+func newRecordingMigration(cmd config.CmdConfig, files ...MigrationFile) (*Migration, *recordingDB) {
+	db := &recordingDB{}
+	return &Migration{
+		UpFiles:       files,
+		repo:          recordingRepository{sql.OpenDB(db)},
+		statusGetter:  &mockStatusGetter{},
+		schemaReader:  &mockSchemaReader{},
+		schemaUpdater: recordingUpdater{db},
+		config:        &config.Config{Command: cmd},
+	}, db
+}
+
 func TestMigration_Versions(t *testing.T) {
 	type fields struct {
 		CurrentVersion string
@@ -82,3 +199,66 @@ func TestMigration_Load(t *testing.T) {
 	assert.Equal(t, "20250830133956", m.UpFiles[3].Version())
 	assert.Equal(t, "20250830133956", m.DownFiles[3].Version())
 }
+
+func TestMigration_FindDownFile(t *testing.T) {
+	dir := t.TempDir()
+
+	m := &Migration{
+		UpFiles: []MigrationFile{
+			writeMigrationFile(t, dir, "20230101_init.up.sql", "CREATE TABLE a (id INT);"),
+			writeMigrationFile(t, dir, "20230201_drop_column.up.sql", "-- migrate:irreversible\nALTER TABLE a DROP COLUMN id;"),
+			writeMigrationFile(t, dir, "20230301_backfill.up.sql", "UPDATE a SET id = 1;"),
+		},
+		DownFiles: []MigrationFile{
+			writeMigrationFile(t, dir, "20230101_init.down.sql", "DROP TABLE a;"),
+			writeMigrationFile(t, dir, "20230201_drop_column.down.sql", "SELECT 1;"),
+		},
+	}
+
+	file, err := m.FindDownFile("20230101")
+	assert.NoError(t, err)
+	assert.Equal(t, "20230101", file.Version())
+	assert.True(t, file.IsDown())
+
+	var irreversible *IrreversibleMigrationError
+	_, err = m.FindDownFile("20230201")
+	assert.ErrorAs(t, err, &irreversible)
+	assert.Equal(t, "20230201", irreversible.Version)
+
+	_, err = m.FindDownFile("20230301")
+	assert.ErrorAs(t, err, &irreversible)
+	assert.Equal(t, "20230301", irreversible.Version)
+	assert.Equal(t, "no down file", irreversible.Reason)
+
+	_, err = m.FindDownFile("20230401")
+	assert.Error(t, err)
+}
+
+func TestMigration_Down_NothingApplied(t *testing.T) {
+	m := &Migration{
+		statusGetter: &mockStatusGetter{},
+		config:       &config.Config{},
+	}
+
+	assert.Error(t, m.Down())
+}
+
+func TestMigration_RollbackBatch_Loaded(t *testing.T) {
+	dir := t.TempDir()
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir},
+		writeMigrationFile(t, dir, "0001_users.up.sql", "CREATE TABLE users (id INT);\n"),
+		writeMigrationFile(t, dir, "0002_posts.up.sql", "CREATE TABLE posts (id INT);\n"),
+		writeMigrationFile(t, dir, "0003_email.up.sql", "ALTER TABLE users ADD email TEXT;\n"))
+	m.DownFiles = []MigrationFile{writeMigrationFile(t, dir, "0003_email.down.sql", "ALTER TABLE users DROP email;\n")}
+	// A table upgraded from layout 1 keeps the versions applied before
+	// batches in batch 0; schema load records its own apart.
+	reader := &mockSchemaReader{batches: map[int][]string{0: {"0001"}, loadedBatch: {"0002"}}}
+	m.schemaReader = reader
+
+	assert.NoError(t, m.RollbackBatch())
+	assert.Empty(t, db.entries())
+
+	reader.batches[1] = []string{"0003"}
+	assert.NoError(t, m.RollbackBatch())
+	assert.Equal(t, []string{"BEGIN", "ALTER TABLE users DROP email", "remove 0003", "COMMIT"}, db.entries())
+}
@@ -0,0 +1,276 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// defaultPreflightTransactionAge is the age from which an open transaction
+// blocks the migrations when none is configured.
+const defaultPreflightTransactionAge = time.Minute
+
+// preflightPollInterval is how often the sessions are checked again while
+// waiting for them to finish.
+const preflightPollInterval = 5 * time.Second
+
+type activityReader interface {
+	BlockingSessions(ctx context.Context, maxAge time.Duration, tables []string) ([]BlockingSession, error)
+}
+
+// BlockingSession is another session of the database that would hold up the
+// DDL of the migrations, or be held up by it while queuing other queries.
+type BlockingSession struct {
+	PID         int
+	User        string
+	Application string
+	State       string
+	// TransactionAge is how long its current transaction has been open.
+	TransactionAge time.Duration
+	Query          string
+	// Tables are the tables referenced by the migrations it holds locks on.
+	Tables []string
+}
+
+func (s BlockingSession) String() string {
+	who := s.User
+	if s.Application != "" {
+		who += " (" + s.Application + ")"
+	}
+	str := fmt.Sprintf("pid %d %s, %s, transaction open for %s", s.PID, who, s.State, s.TransactionAge.Round(time.Second))
+	if len(s.Tables) > 0 {
+		str += ", locks " + strings.Join(s.Tables, ", ")
+	}
+	if s.Query != "" {
+		str += ": " + oneLine(s.Query)
+	}
+	return str
+}
+
+// PreflightError is returned when sessions would block the migrations.
+type PreflightError struct {
+	Sessions []BlockingSession
+}
+
+func (e *PreflightError) Error() string {
+	lines := []string{fmt.Sprintf("%d session(s) would block the migrations:", len(e.Sessions))}
+	for _, s := range e.Sessions {
+		lines = append(lines, "  "+s.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PreflightOption overrides the preflight settings of the configuration for
+// one call.
+type PreflightOption func(*preflightSettings)
+
+type preflightSettings struct {
+	enabled        bool
+	wait           time.Duration
+	transactionAge time.Duration
+}
+
+// WithPreflight enables the preflight check, waiting up to wait for the
+// blocking sessions to finish. A zero wait keeps the configured one.
+func WithPreflight(wait time.Duration) PreflightOption {
+	return func(s *preflightSettings) {
+		s.enabled = true
+		if wait > 0 {
+			s.wait = wait
+		}
+	}
+}
+
+// WithPreflightTransactionAge sets the age from which an open transaction
+// blocks the migrations.
+func WithPreflightTransactionAge(age time.Duration) PreflightOption {
+	return func(s *preflightSettings) {
+		s.transactionAge = age
+	}
+}
+
+// preflightSettings returns the configured preflight settings with opts
+// applied.
+func (m *Migration) preflightSettings(opts []PreflightOption) preflightSettings {
+	s := preflightSettings{transactionAge: defaultPreflightTransactionAge}
+	if m.config != nil {
+		s.enabled = m.config.Command.Preflight
+		s.wait = m.config.Command.PreflightWait
+		if m.config.Command.PreflightTransactionAge > 0 {
+			s.transactionAge = m.config.Command.PreflightTransactionAge
+		}
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// BlockingSessions returns the sessions with a transaction open for longer
+// than the configured preflight_transaction_age, or holding a lock on a table
+// referenced by the pending migrations.
+func (m *Migration) BlockingSessions(opts ...PreflightOption) ([]BlockingSession, error) {
+	pending, err := m.PendingFiles()
+	if err != nil {
+		return nil, err
+	}
+	tables, err := m.ReferencedTables(pending)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.activity.BlockingSessions(m.context(), m.preflightSettings(opts).transactionAge, tables)
+}
+
+// Preflight checks for blocking sessions. It fails with a PreflightError
+// when there are some, after waiting up to wait for them to finish.
+func (m *Migration) Preflight(wait time.Duration, opts ...PreflightOption) error {
+	deadline := time.Now().Add(wait)
+	for {
+		sessions, err := m.BlockingSessions(opts...)
+		if err != nil {
+			return fmt.Errorf("preflight check failed: %w", err)
+		}
+		if len(sessions) == 0 {
+			m.logf("Preflight: no blocking sessions")
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return &PreflightError{Sessions: sessions}
+		}
+		m.logf("Preflight: waiting up to %s for %d blocking session(s):", remaining.Round(time.Second), len(sessions))
+		for _, s := range sessions {
+			m.logf("  %s", s)
+		}
+		select {
+		case <-time.After(min(remaining, preflightPollInterval)):
+		case <-m.context().Done():
+			return m.context().Err()
+		}
+	}
+}
+
+// preflight runs Preflight when the configuration or opts enable it.
+func (m *Migration) preflight(opts []PreflightOption) error {
+	s := m.preflightSettings(opts)
+	if !s.enabled {
+		return nil
+	}
+	return m.Preflight(s.wait, opts...)
+}
+
+// ReferencedTables returns the tables that the SQL of files alters, indexes,
+// drops, truncates, adds triggers to or references with a foreign key, as
+// written in the files. Go migrations are skipped.
+func (m *Migration) ReferencedTables(files []MigrationFile) ([]string, error) {
+	seen := map[string]bool{}
+	for _, file := range files {
+		if file.IsGo() {
+			continue
+		}
+		content, err := m.Render(file)
+		if err != nil {
+			return nil, err
+		}
+		statements, err := SplitStatements(m.dialect(), content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split migration file %s: %w", file.Path, err)
+		}
+		for _, stmt := range statements {
+			for _, table := range statementTables(stmt.SQL) {
+				seen[table] = true
+			}
+		}
+	}
+
+	tables := make([]string, 0, len(seen))
+	for table := range seen {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+var (
+	dropTablePattern     = regexp.MustCompile(`^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(` + qualifiedIdentifier + `)`)
+	truncatePattern      = regexp.MustCompile(`^TRUNCATE\s+(?:TABLE\s+)?(?:ONLY\s+)?(` + qualifiedIdentifier + `)`)
+	createTriggerPattern = regexp.MustCompile(`(?s)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:CONSTRAINT\s+)?TRIGGER\b.*?\bON\s+(` + qualifiedIdentifier + `)`)
+	referencesPattern    = regexp.MustCompile(`\bREFERENCES\s+(` + qualifiedIdentifier + `)`)
+)
+
+// statementTables returns the tables locked by one statement, in the case
+// they are written in.
+func statementTables(sql string) []string {
+	upper := strings.ToUpper(blankLiterals(sql))
+	original := func(start, end int) string {
+		// Upper-casing keeps the offsets unless the statement has non-ASCII
+		// text, in which case quoted names lose their case.
+		if len(upper) == len(sql) {
+			return sql[start:end]
+		}
+		return upper[start:end]
+	}
+
+	var tables []string
+	for _, pattern := range []*regexp.Regexp{alterTablePattern, dropTablePattern, truncatePattern, createTriggerPattern} {
+		if match := pattern.FindStringSubmatchIndex(upper); match != nil {
+			tables = append(tables, original(match[2], match[3]))
+		}
+	}
+	if match := createIndexPattern.FindStringSubmatchIndex(upper); match != nil {
+		tables = append(tables, original(match[4], match[5]))
+	}
+	for _, match := range referencesPattern.FindAllStringSubmatchIndex(upper, -1) {
+		tables = append(tables, original(match[2], match[3]))
+	}
+	return tables
+}
+
+// BlockingSessions lists the other sessions of the database with a
+// transaction open for longer than maxAge or holding a lock on one of tables.
+// Tables that don't exist yet are ignored.
+func (r *repository) BlockingSessions(ctx context.Context, maxAge time.Duration, tables []string) ([]BlockingSession, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH referenced AS (
+			SELECT to_regclass(name) AS oid FROM unnest($2::text[]) AS name
+		), locked AS (
+			SELECT l.pid, array_agg(DISTINCT l.relation::regclass::text ORDER BY l.relation::regclass::text) AS tables
+			FROM pg_locks l
+			JOIN referenced ON referenced.oid = l.relation
+			WHERE l.granted AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+			GROUP BY l.pid
+		)
+		SELECT a.pid, coalesce(a.usename, ''), coalesce(a.application_name, ''), coalesce(a.state, ''),
+			extract(epoch FROM now() - a.xact_start)::float8, coalesce(a.query, ''), coalesce(locked.tables, '{}')
+		FROM pg_stat_activity a
+		LEFT JOIN locked ON locked.pid = a.pid
+		WHERE a.pid <> pg_backend_pid()
+			AND a.datname = current_database()
+			AND a.xact_start IS NOT NULL
+			AND (a.xact_start < now() - $1::float8 * interval '1 second' OR locked.pid IS NOT NULL)
+		ORDER BY a.xact_start`,
+		maxAge.Seconds(), pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []BlockingSession
+	for rows.Next() {
+		var s BlockingSession
+		var age float64
+		if err := rows.Scan(&s.PID, &s.User, &s.Application, &s.State, &age, &s.Query, pq.Array(&s.Tables)); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		s.TransactionAge = time.Duration(age * float64(time.Second))
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
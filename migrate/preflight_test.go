@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockActivityReader struct {
+	sessions [][]BlockingSession
+	calls    int
+	maxAge   time.Duration
+	tables   []string
+}
+
+func (r *mockActivityReader) BlockingSessions(ctx context.Context, maxAge time.Duration, tables []string) ([]BlockingSession, error) {
+	r.maxAge, r.tables = maxAge, tables
+	sessions := r.sessions[min(r.calls, len(r.sessions)-1)]
+	r.calls++
+	return sessions, nil
+}
+
+func TestStatementTables(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"ALTER TABLE public.users ADD COLUMN email TEXT", []string{"public.users"}},
+		{"ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id)", []string{"orders", "users"}},
+		{"CREATE INDEX CONCURRENTLY orders_user_idx ON orders (user_id)", []string{"orders"}},
+		{`DROP TABLE IF EXISTS "Legacy"`, []string{`"Legacy"`}},
+		{"TRUNCATE TABLE sessions", []string{"sessions"}},
+		{"CREATE TRIGGER touch\n  BEFORE UPDATE ON accounts\n  FOR EACH ROW EXECUTE FUNCTION touch()", []string{"accounts"}},
+		{"CREATE TABLE invoices (id INT, account_id INT REFERENCES accounts)", []string{"accounts"}},
+		{"UPDATE users SET note = 'ALTER TABLE secrets ADD x INT'", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, statementTables(tt.sql), tt.sql)
+	}
+}
+
+func TestMigration_Preflight(t *testing.T) {
+	dir := t.TempDir()
+	blocking := BlockingSession{PID: 4242, User: "app", Application: "worker", State: "idle in transaction",
+		TransactionAge: 95 * time.Second, Query: "SELECT *\n  FROM users", Tables: []string{"users"}}
+	activity := &mockActivityReader{sessions: [][]BlockingSession{{blocking}, nil}}
+	m := &Migration{
+		UpFiles: []MigrationFile{
+			writeMigrationFile(t, dir, "20250101_users.up.sql", "CREATE TABLE users (id INT);\n"),
+			writeMigrationFile(t, dir, "20250102_email.up.sql", "ALTER TABLE users ADD email TEXT;\nCREATE INDEX users_email ON users (email);\n"),
+			writeMigrationFile(t, dir, "20250103_orders.up.sql", "ALTER TABLE orders ADD user_id INT REFERENCES users;\n"),
+		},
+		statusGetter: &mockStatusGetter{version: "20250101"},
+		schemaReader: &mockSchemaReader{},
+		activity:     activity,
+		config:       &config.Config{Command: config.CmdConfig{MigrationDir: dir}},
+	}
+
+	sessions, err := m.BlockingSessions()
+	assert.NoError(t, err)
+	assert.Equal(t, []BlockingSession{blocking}, sessions)
+	assert.Equal(t, []string{"orders", "users"}, activity.tables)
+	assert.Equal(t, time.Minute, activity.maxAge)
+	assert.Equal(t, "pid 4242 app (worker), idle in transaction, transaction open for 1m35s, locks users: SELECT * FROM users", blocking.String())
+
+	activity.calls = 0
+	err = m.Preflight(0)
+	assert.Equal(t, &PreflightError{Sessions: []BlockingSession{blocking}}, err)
+	assert.Equal(t, 1, activity.calls)
+
+	m.config.Command.PreflightTransactionAge = 10 * time.Minute
+	activity.calls = 0
+	assert.NoError(t, m.Preflight(50*time.Millisecond))
+	assert.Equal(t, 2, activity.calls)
+	assert.Equal(t, 10*time.Minute, activity.maxAge)
+}
+
+func TestMigration_PreflightOptions(t *testing.T) {
+	dir := t.TempDir()
+	activity := &mockActivityReader{sessions: [][]BlockingSession{{{PID: 4242, User: "app"}}}}
+	m := &Migration{
+		UpFiles:      []MigrationFile{writeMigrationFile(t, dir, "20250101_users.up.sql", "CREATE TABLE users (id INT);\n")},
+		statusGetter: &mockStatusGetter{},
+		schemaReader: &mockSchemaReader{},
+		activity:     activity,
+		config:       &config.Config{Command: config.CmdConfig{MigrationDir: dir, PreflightTransactionAge: 10 * time.Minute}},
+	}
+
+	assert.NoError(t, m.preflight(nil))
+	assert.Equal(t, 0, activity.calls)
+
+	err := m.preflight([]PreflightOption{WithPreflight(0), WithPreflightTransactionAge(time.Second)})
+	assert.IsType(t, &PreflightError{}, err)
+	assert.Equal(t, time.Second, activity.maxAge)
+	// The options apply to the call only.
+	assert.Equal(t, config.CmdConfig{MigrationDir: dir, PreflightTransactionAge: 10 * time.Minute}, m.config.Command)
+}
@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// GoMigrationFunc is the body of a migration written in Go. It runs inside
+// the transaction of the migration run.
+type GoMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+type goMigration struct {
+	version string
+	name    string
+	up      GoMigrationFunc
+	down    GoMigrationFunc
+}
+
+var registry = struct {
+	sync.Mutex
+	migrations map[string]goMigration
+}{migrations: map[string]goMigration{}}
+
+// Register adds a migration implemented in Go. It is meant to be called from
+// an init function of a package imported by the binary running the
+// migrations. Registered migrations are merged with the SQL files by Load and
+// ordered by version. A nil down makes the migration irreversible.
+// Register panics when the version or name is invalid or the version is
+// already registered.
+func Register(version string, name string, up GoMigrationFunc, down GoMigrationFunc) {
+	if up == nil {
+		panic(fmt.Sprintf("migrate: Register %s: up function is nil", version))
+	}
+	fileName := goMigrationFileName(version, name, "up")
+	if _, _, _, err := ParseMigrationFileName(fileName); err != nil {
+		panic(fmt.Sprintf("migrate: Register %s_%s: invalid version or name", version, name))
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.migrations[version]; ok {
+		panic(fmt.Sprintf("migrate: Register called twice for version %s", version))
+	}
+	registry.migrations[version] = goMigration{version: version, name: name, up: up, down: down}
+}
+
+// goMigrationFileName builds the virtual file name a Go migration is known
+// by, so that it can be handled like a SQL file, e.g. "20250901_backfill.up.go".
+func goMigrationFileName(version string, name string, kind string) string {
+	return fmt.Sprintf("%s_%s.%s.go", version, name, kind)
+}
+
+// registeredFiles returns the registered Go migrations as up and down
+// migration files, sorted by version.
+func registeredFiles() ([]MigrationFile, []MigrationFile) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	var upFiles, downFiles []MigrationFile
+	for _, migration := range registry.migrations {
+		upFiles = append(upFiles, MigrationFile{
+			Path: goMigrationFileName(migration.version, migration.name, "up"),
+			Kind: "up",
+			fn:   migration.up,
+		})
+		if migration.down != nil {
+			downFiles = append(downFiles, MigrationFile{
+				Path: goMigrationFileName(migration.version, migration.name, "down"),
+				Kind: "down",
+				fn:   migration.down,
+			})
+		}
+	}
+
+	sortFiles(upFiles)
+	sortFiles(downFiles)
+	return upFiles, downFiles
+}
+
+func sortFiles(files []MigrationFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Version() < files[j].Version()
+	})
+}
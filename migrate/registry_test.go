@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func withEmptyRegistry(t *testing.T) {
+	t.Helper()
+	registry.Lock()
+	saved := registry.migrations
+	registry.migrations = map[string]goMigration{}
+	registry.Unlock()
+
+	t.Cleanup(func() {
+		registry.Lock()
+		registry.migrations = saved
+		registry.Unlock()
+	})
+}
+
+func noopMigration(ctx context.Context, tx *sql.Tx) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	withEmptyRegistry(t)
+
+	Register("20250901000000", "backfill", noopMigration, noopMigration)
+
+	assert.Panics(t, func() { Register("20250901000000", "again", noopMigration, nil) })
+	assert.Panics(t, func() { Register("v1", "invalid", noopMigration, nil) })
+	assert.Panics(t, func() { Register("20250902000000", "no_up", nil, nil) })
+}
+
+func TestMigration_Load_WithGoMigrations(t *testing.T) {
+	withEmptyRegistry(t)
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"20250101_init.up.sql",
+		"20250101_init.down.sql",
+		"20250301_add_index.up.sql",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	Register("20250201", "rehash_passwords", noopMigration, nil)
+
+	m := &Migration{
+		statusGetter: &mockStatusGetter{},
+		config:       &config.Config{},
+	}
+	if err := m.Load(dir); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	assert.Equal(t, []string{"20250101", "20250201", "20250301"}, m.Versions())
+	assert.True(t, m.UpFiles[1].IsGo())
+	assert.Equal(t, "rehash_passwords", m.UpFiles[1].Name())
+	assert.Len(t, m.DownFiles, 1)
+
+	var irreversible *IrreversibleMigrationError
+	_, err := m.FindDownFile("20250201")
+	assert.ErrorAs(t, err, &irreversible)
+
+	Register("20250101", "conflict", noopMigration, nil)
+	problems, err := Validate(dir)
+	assert.NoError(t, err)
+	assert.Len(t, problems, 1)
+}
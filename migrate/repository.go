@@ -3,20 +3,35 @@ package migrate
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/gooolib/errors"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// DefaultTable is the name of the history table unless configured otherwise.
+const DefaultTable = "schema_migrations"
+
+// DefaultSeedTable is the name of the table recording seeds unless configured otherwise.
+const DefaultSeedTable = "schema_seeds"
+
 type repository struct {
 	db *sql.DB
+	// tableSchema and tableName locate the history table; tableSchema is
+	// empty when the table lives in the search_path.
+	tableSchema string
+	tableName   string
+	// table is the quoted, schema-qualified name of the history table.
+	table string
+	// seedTable is the quoted, schema-qualified name of the seed history table.
+	seedTable string
 }
 
 func (r *repository) DB() *sql.DB {
 	return r.db
 }
 
-func newRepository(dbURL string) (*repository, error) {
+func newRepository(dbURL string, schema string, table string, seedTable string) (*repository, error) {
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -26,7 +41,28 @@ func newRepository(dbURL string) (*repository, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &repository{db: db}, nil
+	if table == "" {
+		table = DefaultTable
+	}
+	if seedTable == "" {
+		seedTable = DefaultSeedTable
+	}
+
+	return &repository{
+		db:          db,
+		tableSchema: schema,
+		tableName:   table,
+		table:       qualifiedName(schema, table),
+		seedTable:   qualifiedName(schema, seedTable),
+	}, nil
+}
+
+// qualifiedName quotes a table name and its optional schema for use in SQL.
+func qualifiedName(schema string, name string) string {
+	if schema == "" {
+		return pq.QuoteIdentifier(name)
+	}
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(name)
 }
 
 func (r *repository) Close() error {
@@ -37,22 +73,14 @@ func (r *repository) Close() error {
 }
 
 func (r *repository) CreateMigrationTable() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS schema_migrations (
-		version VARCHAR(255) PRIMARY KEY,
-		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	)`
-
-	_, err := r.db.Exec(query)
-	if err != nil {
+	if err := r.upgradeLayout(); err != nil {
 		return fmt.Errorf("failed to create migration table: %w", err)
 	}
-
 	return nil
 }
 
 func (r *repository) GetCurrentVersion() (string, error) {
-	query := "SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1"
+	query := fmt.Sprintf("SELECT version FROM %s WHERE kind = 'versioned' ORDER BY version DESC LIMIT 1", r.table)
 	var version string
 	err := r.db.QueryRow(query).Scan(&version)
 	if err != nil {
@@ -64,16 +92,82 @@ func (r *repository) GetCurrentVersion() (string, error) {
 	return version, nil
 }
 
-func (r *repository) RecordMigration(tx *sql.Tx, version string) error {
-	query := "INSERT INTO schema_migrations (version) VALUES ($1)"
-	if err := r.execQuery(tx, query, version); err != nil {
+func (r *repository) GetLastBatch() (int, error) {
+	// Versions recorded by schema load have a negative batch and don't count.
+	query := fmt.Sprintf("SELECT COALESCE(MAX(batch), 0) FROM %s WHERE kind = 'versioned' AND batch >= 0", r.table)
+	var batch int
+	if err := r.db.QueryRow(query).Scan(&batch); err != nil {
+		return 0, errors.Wrap(err)
+	}
+	return batch, nil
+}
+
+func (r *repository) ListBatchVersions(batch int) ([]string, error) {
+	query := fmt.Sprintf("SELECT version FROM %s WHERE kind = 'versioned' AND batch = $1 ORDER BY version DESC", r.table)
+	rows, err := r.db.Query(query, batch)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, errors.Wrap(err)
+		}
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	return versions, nil
+}
+
+func (r *repository) RecordMigration(tx *sql.Tx, version string, batch int, checksum string) error {
+	query := fmt.Sprintf("INSERT INTO %s (version, batch, checksum) VALUES ($1, $2, NULLIF($3, ''))", r.table)
+	if err := r.execQuery(tx, query, version, batch, checksum); err != nil {
+		return errors.Wrap(err)
+	}
+	return nil
+}
+
+// RecordRepeatable stores the checksum a repeatable migration was last applied with.
+func (r *repository) RecordRepeatable(tx *sql.Tx, name string, batch int, checksum string) error {
+	query := fmt.Sprintf(`INSERT INTO %s (version, batch, checksum, kind) VALUES ($1, $2, $3, 'repeatable')
+		ON CONFLICT (version) DO UPDATE SET batch = EXCLUDED.batch, checksum = EXCLUDED.checksum, applied_at = now()`, r.table)
+	if err := r.execQuery(tx, query, repeatableKey(name), batch, checksum); err != nil {
 		return errors.Wrap(err)
 	}
 	return nil
 }
 
+// RemoveRepeatableRecords forgets every repeatable migration, so that they
+// are all applied again by the next up.
+func (r *repository) RemoveRepeatableRecords(tx *sql.Tx) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE kind = 'repeatable'", r.table)
+	if err := r.execQuery(tx, query); err != nil {
+		return errors.Wrap(err)
+	}
+	return nil
+}
+
+// ListRepeatableMigrations returns the applied repeatable migrations, with
+// their name in Version.
+func (r *repository) ListRepeatableMigrations() ([]SchemaMigration, error) {
+	return r.listMigrations("repeatable")
+}
+
+// repeatableKey is the version column value of a repeatable migration. The
+// prefix keeps it apart from numeric versions.
+func repeatableKey(name string) string {
+	return "R_" + name
+}
+
 func (r *repository) ExistMigrationRecord(tx *sql.Tx, version string) (bool, error) {
-	query := "SELECT version FROM schema_migrations WHERE version = $1 LIMIT 1"
+	query := fmt.Sprintf("SELECT version FROM %s WHERE version = $1 LIMIT 1", r.table)
 	result := ""
 	if err := tx.QueryRow(query, version).Scan(&result); err != nil {
 		if err == sql.ErrNoRows {
@@ -93,7 +187,7 @@ func (r *repository) RemoveMigrationRecord(tx *sql.Tx, version string) error {
 	if !exist {
 		return nil
 	}
-	query := "DELETE FROM schema_migrations WHERE version = $1"
+	query := fmt.Sprintf("DELETE FROM %s WHERE version = $1", r.table)
 	if err := r.execQuery(tx, query, version); err != nil {
 		return errors.Wrap(err)
 	}
@@ -101,7 +195,7 @@ func (r *repository) RemoveMigrationRecord(tx *sql.Tx, version string) error {
 }
 
 func (r *repository) IsMigrationApplied(version string) (bool, error) {
-	query := "SELECT COUNT(*) FROM schema_migrations WHERE version = $1"
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE version = $1", r.table)
 	var count int
 	err := r.db.QueryRow(query, version).Scan(&count)
 	if err != nil {
@@ -128,10 +222,10 @@ func (r *repository) ResetMigrations() error {
 			tx.Rollback()
 		}
 	}()
-	// Truncate schema_migrations if exists
-	_, err = tx.Exec("TRUNCATE TABLE schema_migrations")
+	// Truncate the history table if exists
+	_, err = tx.Exec(fmt.Sprintf("TRUNCATE TABLE %s", r.table))
 	if err != nil {
-		return fmt.Errorf("failed to truncate schema_migrations table: %w", err)
+		return fmt.Errorf("failed to truncate %s table: %w", r.table, err)
 	}
 
 	// Get all tables in the public schema
@@ -160,7 +254,7 @@ func (r *repository) ResetMigrations() error {
 
 	// Drop all tables
 	for _, table := range tables {
-		_, err := tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", table))
+		_, err := tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", qualifiedName("public", table)))
 		if err != nil {
 			return fmt.Errorf("failed to drop table %s: %w", table, err)
 		}
@@ -174,8 +268,12 @@ func (r *repository) ResetMigrations() error {
 }
 
 func (r *repository) ListAppliedMigrations() ([]SchemaMigration, error) {
-	query := "SELECT version, applied_at FROM schema_migrations ORDER BY version"
-	rows, err := r.db.Query(query)
+	return r.listMigrations("versioned")
+}
+
+func (r *repository) listMigrations(kind string) ([]SchemaMigration, error) {
+	query := fmt.Sprintf("SELECT version, applied_at, batch, COALESCE(checksum, '') FROM %s WHERE kind = $1 ORDER BY version", r.table)
+	rows, err := r.db.Query(query, kind)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -184,9 +282,12 @@ func (r *repository) ListAppliedMigrations() ([]SchemaMigration, error) {
 	var migrations []SchemaMigration
 	for rows.Next() {
 		var m SchemaMigration
-		if err := rows.Scan(&m.Version, &m.AppliedAt); err != nil {
+		if err := rows.Scan(&m.Version, &m.AppliedAt, &m.Batch, &m.Checksum); err != nil {
 			return nil, errors.Wrap(err)
 		}
+		if kind == "repeatable" {
+			m.Version = strings.TrimPrefix(m.Version, repeatableKey(""))
+		}
 		migrations = append(migrations, m)
 	}
 
@@ -209,3 +310,55 @@ func (r *repository) execQuery(tx *sql.Tx, query string, args ...any) error {
 	}
 	return nil
 }
+
+func (r *repository) CreateSeedTable() error {
+	query := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		name VARCHAR(255) PRIMARY KEY,
+		checksum VARCHAR(64),
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`, r.seedTable)
+	if _, err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create seed table: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) ListAppliedSeeds(tx *sql.Tx) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT name FROM %s", r.seedTable))
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.Wrap(err)
+		}
+		applied[name] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	return applied, nil
+}
+
+func (r *repository) RecordSeed(tx *sql.Tx, name string, checksum string) error {
+	query := fmt.Sprintf("INSERT INTO %s (name, checksum) VALUES ($1, NULLIF($2, ''))", r.seedTable)
+	if err := r.execQuery(tx, query, name, checksum); err != nil {
+		return errors.Wrap(err)
+	}
+	return nil
+}
+
+func (r *repository) RemoveSeedRecords(tx *sql.Tx) error {
+	query := fmt.Sprintf("DELETE FROM %s", r.seedTable)
+	if err := r.execQuery(tx, query); err != nil {
+		return errors.Wrap(err)
+	}
+	return nil
+}
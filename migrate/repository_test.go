@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualifiedName(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		table  string
+		want   string
+	}{
+		{
+			name:  "table only",
+			table: "schema_migrations",
+			want:  `"schema_migrations"`,
+		},
+		{
+			name:   "schema and table",
+			schema: "migrate",
+			table:  "history",
+			want:   `"migrate"."history"`,
+		},
+		{
+			name:   "quotes are escaped",
+			schema: `we"ird`,
+			table:  `x"; DROP TABLE users; --`,
+			want:   `"we""ird"."x""; DROP TABLE users; --"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, qualifiedName(tt.schema, tt.table))
+		})
+	}
+}
+
+func TestRepository_metaTable(t *testing.T) {
+	r := &repository{tableSchema: "migrate", tableName: "history", table: qualifiedName("migrate", "history")}
+	other := &repository{tableName: "history", table: qualifiedName("", "history")}
+
+	assert.Equal(t, `"migrate"."history_meta"`, r.metaTable())
+	assert.Equal(t, `"history_meta"`, other.metaTable())
+	assert.Equal(t, r.lockKey(), r.lockKey())
+	assert.NotEqual(t, r.lockKey(), other.lockKey())
+}
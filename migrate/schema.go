@@ -0,0 +1,409 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the database structure read from the Postgres catalog. Names
+// are quoted where needed and schema-qualified, and every list is sorted so
+// that two inspections of the same database are equal.
+type Schema struct {
+	Schemas    []string
+	Extensions []Extension
+	Enums      []Enum
+	Domains    []Domain
+	Types      []CompositeType
+	Sequences  []Sequence
+	Functions  []Function
+	Tables     []Table
+	Views      []View
+	// Versions are the applied migration versions the structure results from.
+	Versions []string
+}
+
+type Extension struct {
+	Name   string
+	Schema string
+}
+
+type Enum struct {
+	Name   string
+	Labels []string
+}
+
+type Domain struct {
+	Name    string
+	Type    string
+	Default string
+	NotNull bool
+	// Checks are the CHECK constraints of the domain, as
+	// "CONSTRAINT name CHECK (...)".
+	Checks []string
+}
+
+// CompositeType is a type created with CREATE TYPE ... AS (...).
+type CompositeType struct {
+	Name string
+	// Attributes are the attributes of the type in order, as "name type".
+	Attributes []string
+}
+
+type Sequence struct {
+	Name      string
+	Type      string
+	Start     int64
+	Increment int64
+	Min       int64
+	Max       int64
+	Cache     int64
+	Cycle     bool
+	// OwnedBy is the column the sequence belongs to, as table.column.
+	OwnedBy string
+}
+
+type Function struct {
+	Name string
+	// Arguments are the types of the input arguments, which tell overloaded
+	// functions apart.
+	Arguments string
+	// Parameters are the arguments with their names, modes and defaults, and
+	// Result the return type. CREATE OR REPLACE can't change either.
+	Parameters string
+	Result     string
+	Definition string
+	// DependsOn lists the tables whose row type the arguments or the result
+	// of the function use, which must exist before it.
+	DependsOn []string
+}
+
+type Table struct {
+	Name string
+	// PartitionBy is the partition key of a partitioned table, as in
+	// "RANGE (created_at)".
+	PartitionBy string
+	// PartitionOf is the parent of a partition and PartitionBound its bound,
+	// as in "FOR VALUES FROM ('2025-01-01') TO ('2025-02-01')" or "DEFAULT".
+	PartitionOf    string
+	PartitionBound string
+	// Columns are those of the parent for a partition, which inherits them.
+	Columns     []Column
+	Constraints []Constraint
+	Indexes     []Index
+	Triggers    []Trigger
+}
+
+type Column struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Default string
+	// Identity is "always" or "by default" for identity columns.
+	Identity string
+	// Generated is the expression of a stored generated column.
+	Generated string
+}
+
+// Constraint types, as in pg_constraint.contype.
+const (
+	ConstraintPrimaryKey = "p"
+	ConstraintUnique     = "u"
+	ConstraintCheck      = "c"
+	ConstraintForeignKey = "f"
+	ConstraintExclusion  = "x"
+)
+
+type Constraint struct {
+	Name       string
+	Type       string
+	Definition string
+}
+
+// Index is an index that doesn't back a constraint.
+type Index struct {
+	Name       string
+	Definition string
+}
+
+type Trigger struct {
+	Name       string
+	Definition string
+}
+
+type View struct {
+	Name         string
+	Materialized bool
+	Definition   string
+	// DependsOn lists the other views the view reads from.
+	DependsOn []string
+}
+
+// directiveAppliedVersion records in a schema dump a version whose changes
+// the dump contains: "-- migrate:applied-version 20250101120000".
+const directiveAppliedVersion = "applied-version"
+
+// SQL returns the DDL that recreates the schema, in an order that satisfies
+// dependencies between objects.
+func (s *Schema) SQL() string {
+	var b strings.Builder
+	b.WriteString("-- Schema dump generated by migrate. Do not edit.\n\n")
+	// Function bodies may use tables created after them. SET LOCAL keeps the
+	// setting to the transaction loading the dump.
+	b.WriteString("SET LOCAL check_function_bodies = false;\n")
+
+	for _, name := range s.Schemas {
+		fmt.Fprintf(&b, "\nCREATE SCHEMA IF NOT EXISTS %s;\n", name)
+	}
+	for _, ext := range s.Extensions {
+		fmt.Fprintf(&b, "\nCREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s;\n", ext.Name, ext.Schema)
+	}
+	for _, enum := range s.Enums {
+		fmt.Fprintf(&b, "\nCREATE TYPE %s AS ENUM (\n    %s\n);\n", enum.Name, strings.Join(quoteLiterals(enum.Labels), ",\n    "))
+	}
+	for _, domain := range s.Domains {
+		b.WriteString("\n" + domain.SQL() + "\n")
+	}
+	for _, typ := range s.Types {
+		b.WriteString("\n" + typ.SQL() + "\n")
+	}
+	for _, seq := range s.Sequences {
+		b.WriteString("\n" + seq.SQL() + "\n")
+	}
+	for _, fn := range s.Functions {
+		if len(fn.DependsOn) == 0 {
+			b.WriteString("\n" + fn.SQL() + "\n")
+		}
+	}
+	for _, table := range sortTables(s.Tables) {
+		b.WriteString("\n" + table.SQL() + "\n")
+	}
+	for _, fn := range s.Functions {
+		if len(fn.DependsOn) > 0 {
+			b.WriteString("\n" + fn.SQL() + "\n")
+		}
+	}
+	for _, seq := range s.Sequences {
+		if seq.OwnedBy != "" {
+			fmt.Fprintf(&b, "\nALTER SEQUENCE %s OWNED BY %s;\n", seq.Name, seq.OwnedBy)
+		}
+	}
+	for _, view := range sortViews(s.Views) {
+		b.WriteString("\n" + view.SQL() + "\n")
+	}
+	for _, table := range s.Tables {
+		for _, c := range table.Constraints {
+			if c.Type == ConstraintForeignKey {
+				fmt.Fprintf(&b, "\n%s\n", c.AddSQL(table.Name))
+			}
+		}
+	}
+	for _, table := range s.Tables {
+		for _, idx := range table.Indexes {
+			b.WriteString("\n" + idx.SQL() + "\n")
+		}
+	}
+	for _, table := range s.Tables {
+		for _, trigger := range table.Triggers {
+			b.WriteString("\n" + trigger.SQL() + "\n")
+		}
+	}
+
+	if len(s.Versions) > 0 {
+		b.WriteString("\n")
+		for _, version := range s.Versions {
+			fmt.Fprintf(&b, "%s%s %s\n", directivePrefix, directiveAppliedVersion, version)
+		}
+	}
+	return b.String()
+}
+
+// SQL returns the CREATE DOMAIN statement.
+func (d Domain) SQL() string {
+	sql := "CREATE DOMAIN " + d.Name + " AS " + d.Type
+	if d.Default != "" {
+		sql += " DEFAULT " + d.Default
+	}
+	if d.NotNull {
+		sql += " NOT NULL"
+	}
+	for _, check := range d.Checks {
+		sql += "\n    " + check
+	}
+	return sql + ";"
+}
+
+// SQL returns the CREATE TYPE statement.
+func (t CompositeType) SQL() string {
+	return fmt.Sprintf("CREATE TYPE %s AS (\n    %s\n);", t.Name, strings.Join(t.Attributes, ",\n    "))
+}
+
+// SQL returns the CREATE SEQUENCE statement, without ownership.
+func (s Sequence) SQL() string {
+	cycle := "NO CYCLE"
+	if s.Cycle {
+		cycle = "CYCLE"
+	}
+	return fmt.Sprintf("CREATE SEQUENCE %s\n    AS %s\n    START WITH %d\n    INCREMENT BY %d\n    MINVALUE %d\n    MAXVALUE %d\n    CACHE %d\n    %s;",
+		s.Name, s.Type, s.Start, s.Increment, s.Min, s.Max, s.Cache, cycle)
+}
+
+func (f Function) SQL() string {
+	return strings.TrimRight(f.Definition, "\n; ") + ";"
+}
+
+// SQL returns the CREATE TABLE statement with every constraint except
+// foreign keys, which may reference tables created later. A partition is
+// created as a partition of its parent, whose columns it inherits.
+func (t Table) SQL() string {
+	var lines []string
+	if t.PartitionOf == "" {
+		for _, col := range t.Columns {
+			lines = append(lines, "    "+col.SQL())
+		}
+	}
+	for _, c := range t.Constraints {
+		if c.Type != ConstraintForeignKey {
+			lines = append(lines, fmt.Sprintf("    CONSTRAINT %s %s", c.Name, c.Definition))
+		}
+	}
+
+	create := "CREATE TABLE " + t.Name
+	if t.PartitionOf != "" {
+		create += " PARTITION OF " + t.PartitionOf
+	}
+	switch {
+	case len(lines) > 0:
+		create += " (\n" + strings.Join(lines, ",\n") + "\n)"
+	case t.PartitionOf == "":
+		create += " ()"
+	}
+	if t.PartitionOf != "" {
+		create += " " + t.PartitionBound
+	}
+	if t.PartitionBy != "" {
+		create += " PARTITION BY " + t.PartitionBy
+	}
+	return create + ";"
+}
+
+// Partitioning describes how the table is partitioned or what it is a
+// partition of, empty for a plain table.
+func (t Table) Partitioning() string {
+	var parts []string
+	if t.PartitionOf != "" {
+		parts = append(parts, "PARTITION OF "+t.PartitionOf+" "+t.PartitionBound)
+	}
+	if t.PartitionBy != "" {
+		parts = append(parts, "PARTITION BY "+t.PartitionBy)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c Column) SQL() string {
+	def := c.Name + " " + c.Type
+	switch {
+	case c.Generated != "":
+		def += " GENERATED ALWAYS AS (" + c.Generated + ") STORED"
+	case c.Identity != "":
+		def += " GENERATED " + strings.ToUpper(c.Identity) + " AS IDENTITY"
+	case c.Default != "":
+		def += " DEFAULT " + c.Default
+	}
+	if c.NotNull {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// AddSQL returns the ALTER TABLE statement adding the constraint to table.
+func (c Constraint) AddSQL(table string) string {
+	return fmt.Sprintf("ALTER TABLE ONLY %s\n    ADD CONSTRAINT %s %s;", table, c.Name, c.Definition)
+}
+
+func (i Index) SQL() string {
+	return i.Definition + ";"
+}
+
+func (t Trigger) SQL() string {
+	return t.Definition + ";"
+}
+
+func (v View) SQL() string {
+	body := strings.TrimRight(strings.TrimSpace(v.Definition), ";")
+	if v.Materialized {
+		return fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS\n%s\n  WITH NO DATA;", v.Name, body)
+	}
+	return fmt.Sprintf("CREATE VIEW %s AS\n%s;", v.Name, body)
+}
+
+// sortViews orders views so that each one follows the views it depends on,
+// by name otherwise.
+func sortViews(views []View) []View {
+	byName := make(map[string]View, len(views))
+	names := make([]string, 0, len(views))
+	for _, v := range views {
+		byName[v.Name] = v
+		names = append(names, v.Name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]View, 0, len(views))
+	state := map[string]int{} // 1 while visiting, 2 once emitted
+	var visit func(name string)
+	visit = func(name string) {
+		v, ok := byName[name]
+		if !ok || state[name] != 0 {
+			return
+		}
+		state[name] = 1
+		deps := append([]string(nil), v.DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			visit(dep)
+		}
+		state[name] = 2
+		sorted = append(sorted, v)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return sorted
+}
+
+// sortTables orders tables so that each partition follows its parent, by
+// name otherwise.
+func sortTables(tables []Table) []Table {
+	byName := make(map[string]Table, len(tables))
+	names := make([]string, 0, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]Table, 0, len(tables))
+	emitted := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		t, ok := byName[name]
+		if !ok || emitted[name] {
+			return
+		}
+		emitted[name] = true
+		visit(t.PartitionOf)
+		sorted = append(sorted, t)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return sorted
+}
+
+func quoteLiterals(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return quoted
+}
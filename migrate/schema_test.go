@@ -0,0 +1,181 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema_SQL(t *testing.T) {
+	schema := &Schema{
+		Schemas: []string{"audit"},
+		Enums:   []Enum{{Name: "public.mood", Labels: []string{"ok", "it's fine"}}},
+		Domains: []Domain{{Name: "public.email", Type: "text", NotNull: true, Checks: []string{"CONSTRAINT email_check CHECK ((VALUE ~~ '%@%'::text))"}}},
+		Types:   []CompositeType{{Name: "public.money_amount", Attributes: []string{"amount numeric(12,2)", "currency character(3)"}}},
+		Sequences: []Sequence{{
+			Name: "public.posts_id_seq", Type: "bigint", Start: 1, Increment: 1, Min: 1, Max: 9223372036854775807, Cache: 1,
+			OwnedBy: "public.posts.id",
+		}},
+		Functions: []Function{{
+			Name:       "public.touch",
+			Definition: "CREATE OR REPLACE FUNCTION public.touch()\n RETURNS trigger\n LANGUAGE plpgsql\nAS $function$ BEGIN RETURN NEW; END $function$\n",
+		}},
+		Tables: []Table{
+			{
+				Name: "public.posts",
+				Columns: []Column{
+					{Name: "id", Type: "bigint", NotNull: true, Default: "nextval('public.posts_id_seq'::regclass)"},
+					{Name: "user_id", Type: "integer"},
+				},
+				Constraints: []Constraint{
+					{Name: "posts_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"},
+					{Name: "posts_user_id_fkey", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (user_id) REFERENCES public.users(id)"},
+				},
+				Indexes:  []Index{{Name: "posts_user_id_idx", Definition: "CREATE INDEX posts_user_id_idx ON public.posts USING btree (user_id)"}},
+				Triggers: []Trigger{{Name: "posts_touch", Definition: "CREATE TRIGGER posts_touch BEFORE UPDATE ON public.posts FOR EACH ROW EXECUTE FUNCTION public.touch()"}},
+			},
+			{
+				Name: "public.users",
+				Columns: []Column{
+					{Name: "id", Type: "integer", NotNull: true, Identity: "by default"},
+					{Name: "name", Type: "text"},
+					{Name: "upper_name", Type: "text", Generated: "upper(name)"},
+				},
+			},
+		},
+		Views: []View{
+			{Name: "public.a_recent_posts", Definition: " SELECT id\n   FROM public.all_posts;", DependsOn: []string{"public.all_posts"}},
+			{Name: "public.all_posts", Definition: " SELECT id\n   FROM public.posts;"},
+		},
+		Versions: []string{"20250101120000", "20250102120000"},
+	}
+
+	want := `-- Schema dump generated by migrate. Do not edit.
+
+SET LOCAL check_function_bodies = false;
+
+CREATE SCHEMA IF NOT EXISTS audit;
+
+CREATE TYPE public.mood AS ENUM (
+    'ok',
+    'it''s fine'
+);
+
+CREATE DOMAIN public.email AS text NOT NULL
+    CONSTRAINT email_check CHECK ((VALUE ~~ '%@%'::text));
+
+CREATE TYPE public.money_amount AS (
+    amount numeric(12,2),
+    currency character(3)
+);
+
+CREATE SEQUENCE public.posts_id_seq
+    AS bigint
+    START WITH 1
+    INCREMENT BY 1
+    MINVALUE 1
+    MAXVALUE 9223372036854775807
+    CACHE 1
+    NO CYCLE;
+
+CREATE OR REPLACE FUNCTION public.touch()
+ RETURNS trigger
+ LANGUAGE plpgsql
+AS $function$ BEGIN RETURN NEW; END $function$;
+
+CREATE TABLE public.posts (
+    id bigint DEFAULT nextval('public.posts_id_seq'::regclass) NOT NULL,
+    user_id integer,
+    CONSTRAINT posts_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE public.users (
+    id integer GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    name text,
+    upper_name text GENERATED ALWAYS AS (upper(name)) STORED
+);
+
+ALTER SEQUENCE public.posts_id_seq OWNED BY public.posts.id;
+
+CREATE VIEW public.all_posts AS
+SELECT id
+   FROM public.posts;
+
+CREATE VIEW public.a_recent_posts AS
+SELECT id
+   FROM public.all_posts;
+
+ALTER TABLE ONLY public.posts
+    ADD CONSTRAINT posts_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+
+CREATE INDEX posts_user_id_idx ON public.posts USING btree (user_id);
+
+CREATE TRIGGER posts_touch BEFORE UPDATE ON public.posts FOR EACH ROW EXECUTE FUNCTION public.touch();
+
+-- migrate:applied-version 20250101120000
+-- migrate:applied-version 20250102120000
+`
+	assert.Equal(t, want, schema.SQL())
+
+	statements, err := SplitStatements("postgres", schema.SQL())
+	assert.NoError(t, err)
+	assert.Len(t, statements, 15)
+}
+
+func TestSchema_SQLPartitions(t *testing.T) {
+	schema := &Schema{
+		Functions: []Function{
+			{Name: "public.latest_events", Definition: "CREATE OR REPLACE FUNCTION public.latest_events()\n RETURNS SETOF public.events\n LANGUAGE sql\nAS $function$ SELECT * FROM public.events $function$", DependsOn: []string{"public.events"}},
+			{Name: "public.touch", Definition: "CREATE OR REPLACE FUNCTION public.touch()\n RETURNS trigger\n LANGUAGE plpgsql\nAS $function$ BEGIN RETURN NEW; END $function$"},
+		},
+		Tables: []Table{
+			{
+				Name: "public.a_events_2025", PartitionOf: "public.events", PartitionBound: "FOR VALUES FROM ('2025-01-01') TO ('2026-01-01')",
+				Columns: []Column{{Name: "created_at", Type: "date", NotNull: true}},
+			},
+			{
+				Name: "public.a_events_default", PartitionOf: "public.events", PartitionBound: "DEFAULT",
+				Columns:     []Column{{Name: "created_at", Type: "date", NotNull: true}},
+				Constraints: []Constraint{{Name: "recent", Type: ConstraintCheck, Definition: "CHECK (created_at > '2000-01-01'::date)"}},
+			},
+			{Name: "public.events", PartitionBy: "RANGE (created_at)", Columns: []Column{{Name: "created_at", Type: "date", NotNull: true}}},
+		},
+	}
+
+	assert.Equal(t, `-- Schema dump generated by migrate. Do not edit.
+
+SET LOCAL check_function_bodies = false;
+
+CREATE OR REPLACE FUNCTION public.touch()
+ RETURNS trigger
+ LANGUAGE plpgsql
+AS $function$ BEGIN RETURN NEW; END $function$;
+
+CREATE TABLE public.events (
+    created_at date NOT NULL
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE public.a_events_2025 PARTITION OF public.events FOR VALUES FROM ('2025-01-01') TO ('2026-01-01');
+
+CREATE TABLE public.a_events_default PARTITION OF public.events (
+    CONSTRAINT recent CHECK (created_at > '2000-01-01'::date)
+) DEFAULT;
+
+CREATE OR REPLACE FUNCTION public.latest_events()
+ RETURNS SETOF public.events
+ LANGUAGE sql
+AS $function$ SELECT * FROM public.events $function$;
+`, schema.SQL())
+}
+
+func TestAppliedVersions(t *testing.T) {
+	schema := &Schema{Versions: []string{"0001", "0002"}}
+	assert.Equal(t, []string{"0001", "0002"}, appliedVersions(schema.SQL()))
+	assert.Nil(t, appliedVersions("CREATE TABLE t ();\n-- migrate:applied-version\n"))
+}
+
+func TestSchema_Empty(t *testing.T) {
+	assert.True(t, (&Schema{Schemas: []string{"audit"}, Extensions: []Extension{{Name: "citext", Schema: "public"}}}).Empty())
+	assert.False(t, (&Schema{Tables: []Table{{Name: "public.users"}}}).Empty())
+	assert.False(t, (&Schema{Versions: []string{"0001"}}).Empty())
+}
@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+)
+
+// KindSchema is the Kind of schema files written by DumpSchema.
+const KindSchema = "schema"
+
+// loadedBatch is the batch LoadSchema records versions in. It is negative so
+// that it can't be confused with batch 0, which holds the versions applied
+// before batches were recorded, nor with the batches of up, numbered from 1.
+// The last batch is looked up among the others only, so rollback --batch
+// never picks it up.
+const loadedBatch = -1
+
+// Empty reports whether the schema has no tables, views, sequences,
+// functions, types or applied versions. Schemas and extensions alone don't
+// count, since fresh databases often come with some.
+func (s *Schema) Empty() bool {
+	return len(s.Tables) == 0 && len(s.Views) == 0 && len(s.Sequences) == 0 &&
+		len(s.Functions) == 0 && len(s.Enums) == 0 && len(s.Domains) == 0 && len(s.Types) == 0 &&
+		len(s.Versions) == 0
+}
+
+// appliedVersions returns the versions a schema dump records with the
+// applied-version directive, in order.
+func appliedVersions(content string) []string {
+	var versions []string
+	for _, d := range directives(content) {
+		if d.name == directiveAppliedVersion && d.value != "" {
+			versions = append(versions, d.value)
+		}
+	}
+	return versions
+}
+
+// LoadSchema creates the structure of a schema dump at path, or SchemaFile
+// when path is empty, and records the versions the dump covers as applied,
+// so that up only runs the migrations added since. They are recorded in a
+// batch of their own, which rollback --batch leaves alone. It refuses a database
+// that isn't empty unless force is set, in which case versions already
+// recorded are kept.
+func (m *Migration) LoadSchema(path string, force bool) (err error) {
+	if path == "" {
+		path = m.SchemaFile()
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	if !force {
+		current, err := m.InspectSchema()
+		if err != nil {
+			return fmt.Errorf("failed to inspect schema: %w", err)
+		}
+		if !current.Empty() {
+			return fmt.Errorf("database is not empty (%d tables, %d applied versions), refusing to load %s without force",
+				len(current.Tables), len(current.Versions), path)
+		}
+	}
+
+	statements, err := SplitStatements(m.dialect(), string(content))
+	if err != nil {
+		return fmt.Errorf("failed to split schema file %s: %w", path, err)
+	}
+	tx, err := m.repo.DB().BeginTx(m.context(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Function bodies may use tables created after them. Dumps from older
+	// versions set this for the session, which would outlive the load.
+	if _, err = tx.ExecContext(m.context(), "SET LOCAL check_function_bodies = false"); err != nil {
+		return fmt.Errorf("failed to disable function body checks: %w", err)
+	}
+	for i, stmt := range statements {
+		if stmt.SQL == "SET check_function_bodies = false" {
+			statements[i].SQL = "SET LOCAL check_function_bodies = false"
+		}
+	}
+	if err = m.execStatements(tx, MigrationFile{Path: path, Kind: KindSchema}, statements); err != nil {
+		return err
+	}
+
+	recorded := 0
+	for _, version := range appliedVersions(string(content)) {
+		var applied bool
+		if applied, err = m.IsMigrationApplied(version); err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if applied {
+			continue
+		}
+
+		// The checksum of the local file, when there is one, keeps status
+		// from reporting the migration as modified.
+		checksum := ""
+		if file := m.FindFileByVersion(version, "up"); file != nil {
+			if checksum, err = m.checksum(*file); err != nil {
+				return err
+			}
+		}
+		if err = m.schemaUpdater.RecordMigration(tx, version, loadedBatch, checksum); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		recorded++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	m.logf("Loaded schema from %s and recorded %d migration versions", path, recorded)
+	return nil
+}
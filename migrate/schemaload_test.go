@@ -0,0 +1,30 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigration_LoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.sql")
+	// Dumps from older versions disable the checks for the session.
+	assert.NoError(t, os.WriteFile(path, []byte("SET check_function_bodies = false;\n"+
+		"CREATE TABLE public.users (id integer);\n"+
+		"-- migrate:applied-version 0001\n"), 0o644))
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir})
+
+	assert.NoError(t, m.LoadSchema(path, true))
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SET LOCAL check_function_bodies = false",
+		"SET LOCAL check_function_bodies = false",
+		"CREATE TABLE public.users (id integer)",
+		"record 0001",
+		"COMMIT",
+	}, db.entries())
+}
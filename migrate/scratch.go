@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// withScratchDatabase creates an empty database on the server of m, calls fn
+// with a Migration connected to it that shares the files and settings of m,
+// and drops the database afterwards. The connecting role needs the
+// CREATEDB privilege.
+func (m *Migration) withScratchDatabase(fn func(scratch *Migration) error) (err error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to name scratch database: %w", err)
+	}
+	name := "migrate_scratch_" + hex.EncodeToString(suffix)
+
+	db := m.repo.DB()
+	if _, err := db.ExecContext(m.context(), fmt.Sprintf("CREATE DATABASE %s", pq.QuoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to create scratch database: %w", err)
+	}
+	m.logf("Created scratch database %s", name)
+	defer func() {
+		// Not bound to the context, so that the database is dropped even
+		// when it is cancelled.
+		if _, dropErr := db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", pq.QuoteIdentifier(name))); dropErr != nil {
+			m.logf("Failed to drop scratch database %s: %v", name, dropErr)
+			if err == nil {
+				err = fmt.Errorf("failed to drop scratch database %s: %w", name, dropErr)
+			}
+		}
+	}()
+
+	cfg := *m.config
+	cfg.Database = m.config.Database.WithDatabase(name)
+	// Nothing else uses the scratch database.
+	cfg.Command.Preflight = false
+	scratch, err := NewMigration(&cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to scratch database: %w", err)
+	}
+	defer scratch.repo.Close()
+
+	scratch.UpFiles = m.UpFiles
+	scratch.DownFiles = m.DownFiles
+	scratch.RepeatableFiles = m.RepeatableFiles
+	scratch.ctx = m.ctx
+	scratch.vars = m.vars
+	// Statement logs of the scratch database are noise for the caller.
+	scratch.logger = log.New(io.Discard, "", 0)
+
+	return fn(scratch)
+}
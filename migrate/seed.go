@@ -0,0 +1,210 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gooolib/migration/config"
+)
+
+// KindSeed is the Kind of seed files, which load data rather than change the schema.
+const KindSeed = "seed"
+
+// directiveEnv limits a seed file to a comma-separated list of environments.
+const directiveEnv = "env"
+
+type goSeed struct {
+	name string
+	fn   GoMigrationFunc
+	envs []string
+}
+
+var seedRegistry = struct {
+	sync.Mutex
+	seeds map[string]goSeed
+}{seeds: map[string]goSeed{}}
+
+// RegisterSeed adds a seed implemented in Go, run by Seed together with the
+// SQL seed files in name order. When envs are given the seed only runs in
+// those environments. RegisterSeed panics when the name is already registered.
+func RegisterSeed(name string, fn GoMigrationFunc, envs ...string) {
+	if name == "" || fn == nil {
+		panic("migrate: RegisterSeed requires a name and a function")
+	}
+
+	seedRegistry.Lock()
+	defer seedRegistry.Unlock()
+	if _, ok := seedRegistry.seeds[name]; ok {
+		panic(fmt.Sprintf("migrate: RegisterSeed called twice for seed %s", name))
+	}
+	seedRegistry.seeds[name] = goSeed{name: name, fn: fn, envs: envs}
+}
+
+// seedFile is a seed with the environments it is limited to.
+type seedFile struct {
+	file MigrationFile
+	envs []string
+}
+
+func (s seedFile) runsIn(env string) bool {
+	return len(s.envs) == 0 || slices.Contains(s.envs, env)
+}
+
+// loadSeeds lists the SQL files of dir and the registered Go seeds, sorted
+// by name.
+func (m *Migration) loadSeeds(dir string) ([]seedFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seed files: %w", err)
+	}
+
+	seeds := make([]seedFile, 0, len(paths))
+	names := map[string]bool{}
+	for _, path := range paths {
+		file := MigrationFile{Path: path, Kind: KindSeed}
+		content, err := m.readContent(file)
+		if err != nil {
+			return nil, err
+		}
+		var envs []string
+		if value, ok := findDirective(content, directiveEnv); ok {
+			for _, env := range strings.Split(value, ",") {
+				if env = strings.TrimSpace(env); env != "" {
+					envs = append(envs, env)
+				}
+			}
+		}
+		names[file.Name()] = true
+		seeds = append(seeds, seedFile{file: file, envs: envs})
+	}
+
+	seedRegistry.Lock()
+	for _, seed := range seedRegistry.seeds {
+		if names[seed.name] {
+			seedRegistry.Unlock()
+			return nil, fmt.Errorf("seed %s is defined both in Go and in %s", seed.name, dir)
+		}
+		seeds = append(seeds, seedFile{
+			file: MigrationFile{Path: seed.name + ".go", Kind: KindSeed, fn: seed.fn},
+			envs: seed.envs,
+		})
+	}
+	seedRegistry.Unlock()
+
+	sort.SliceStable(seeds, func(i, j int) bool {
+		return seeds[i].file.Name() < seeds[j].file.Name()
+	})
+	return seeds, nil
+}
+
+// Seed runs, in one transaction, every seed of the configured seed directory
+// and every registered Go seed that applies to the current environment and
+// hasn't run yet. With reset, the configured seed_reset_tables are emptied
+// and the record of previous runs is cleared first, so that every seed runs
+// again; this is refused outside the development and test environments
+// unless force is set.
+func (m *Migration) Seed(reset bool, force bool) (err error) {
+	env := m.env()
+	if reset && !force && env != config.DefaultEnv && env != "test" {
+		return fmt.Errorf("refusing to reset seeds in the %s environment without force", env)
+	}
+
+	if m.config == nil || m.config.Command.SeedDir == "" {
+		return fmt.Errorf("seed directory is not configured")
+	}
+	seeds, err := m.loadSeeds(m.config.Command.SeedDir)
+	if err != nil {
+		return err
+	}
+
+	if err := m.seedStore.CreateSeedTable(); err != nil {
+		return err
+	}
+
+	var ran int
+	err = m.retryLocks("the seeds", func() error {
+		return m.inTransaction(func(tx *sql.Tx) (err error) {
+			ran, err = m.runSeeds(tx, seeds, env, reset)
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logf("Seeded %d file(s) for the %s environment", ran, env)
+	return nil
+}
+
+// runSeeds runs inside tx the seeds that apply to env and haven't run yet,
+// all of them after clearing the seeded tables with reset, and returns how
+// many ran.
+func (m *Migration) runSeeds(tx *sql.Tx, seeds []seedFile, env string, reset bool) (int, error) {
+	if reset {
+		if err := m.clearSeededTables(tx); err != nil {
+			return 0, err
+		}
+		if err := m.seedStore.RemoveSeedRecords(tx); err != nil {
+			return 0, fmt.Errorf("failed to reset seed records: %w", err)
+		}
+	}
+
+	applied, err := m.seedStore.ListAppliedSeeds(tx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list applied seeds: %w", err)
+	}
+
+	ran := 0
+	for _, seed := range seeds {
+		name := seed.file.Name()
+		if !seed.runsIn(env) {
+			m.logf("Skipping seed %s: limited to %s", name, strings.Join(seed.envs, ", "))
+			continue
+		}
+		if applied[name] {
+			continue
+		}
+
+		checksum, err := m.checksum(seed.file)
+		if err != nil {
+			return 0, err
+		}
+		if err := m.runFile(tx, seed.file); err != nil {
+			return 0, err
+		}
+		if err := m.seedStore.RecordSeed(tx, name, checksum); err != nil {
+			return 0, fmt.Errorf("failed to record seed: %w", err)
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// clearSeededTables empties the configured seed_reset_tables, restarting
+// their identity columns, before reset runs the seeds again.
+func (m *Migration) clearSeededTables(tx *sql.Tx) error {
+	tables := m.config.Command.SeedResetTables
+	if len(tables) == 0 {
+		m.logf("No seed_reset_tables configured, running the seeds again over the existing data")
+		return nil
+	}
+
+	quoted := make([]string, 0, len(tables))
+	for _, table := range tables {
+		if schema, name, ok := strings.Cut(table, "."); ok {
+			quoted = append(quoted, qualifiedName(schema, name))
+		} else {
+			quoted = append(quoted, qualifiedName("", table))
+		}
+	}
+	if _, err := tx.ExecContext(m.context(), fmt.Sprintf("TRUNCATE %s RESTART IDENTITY", strings.Join(quoted, ", "))); err != nil {
+		return fmt.Errorf("failed to clear seeded tables: %w", err)
+	}
+	m.logf("Cleared seeded tables %s", strings.Join(tables, ", "))
+	return nil
+}
@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"database/sql"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigration_loadSeeds(t *testing.T) {
+	seedRegistry.Lock()
+	saved := seedRegistry.seeds
+	seedRegistry.seeds = map[string]goSeed{}
+	seedRegistry.Unlock()
+	t.Cleanup(func() {
+		seedRegistry.Lock()
+		seedRegistry.seeds = saved
+		seedRegistry.Unlock()
+	})
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"01_countries.sql":  "INSERT INTO countries VALUES ('JP');",
+		"02_dev_users.sql":  "-- migrate:env development, test\nINSERT INTO users VALUES (1);",
+		"03_reference.sql":  "SELECT 1;",
+		"not_a_seed.sql.sw": "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	RegisterSeed("02_fixtures", noopMigration, "development")
+
+	m := &Migration{config: &config.Config{}}
+	seeds, err := m.loadSeeds(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	names := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		names = append(names, seed.file.Name())
+	}
+	assert.Equal(t, []string{"01_countries", "02_dev_users", "02_fixtures", "03_reference"}, names)
+	assert.True(t, seeds[0].runsIn("production"))
+	assert.Equal(t, []string{"development", "test"}, seeds[1].envs)
+	assert.False(t, seeds[1].runsIn("production"))
+	assert.True(t, seeds[2].file.IsGo())
+	assert.False(t, seeds[2].runsIn("test"))
+
+	RegisterSeed("01_countries", noopMigration)
+	_, err = m.loadSeeds(dir)
+	assert.Error(t, err)
+}
+
+func TestMigration_Seed_ResetRefusedInProduction(t *testing.T) {
+	m := &Migration{config: &config.Config{Env: "production"}}
+
+	assert.Error(t, m.Seed(true, false))
+}
+
+// recordingSeedStore keeps the seed history in memory and logs its changes
+// in a recordingDB.
+type recordingSeedStore struct {
+	db      *recordingDB
+	applied map[string]bool
+}
+
+func (s *recordingSeedStore) CreateSeedTable() error { return nil }
+func (s *recordingSeedStore) ListAppliedSeeds(tx *sql.Tx) (map[string]bool, error) {
+	return maps.Clone(s.applied), nil
+}
+func (s *recordingSeedStore) RecordSeed(tx *sql.Tx, name string, checksum string) error {
+	s.db.record("record seed " + name)
+	s.applied[name] = true
+	return nil
+}
+func (s *recordingSeedStore) RemoveSeedRecords(tx *sql.Tx) error {
+	s.db.record("remove seed records")
+	s.applied = map[string]bool{}
+	return nil
+}
+
+func TestMigration_Seed_Reset(t *testing.T) {
+	seedRegistry.Lock()
+	saved := seedRegistry.seeds
+	seedRegistry.seeds = map[string]goSeed{}
+	seedRegistry.Unlock()
+	t.Cleanup(func() {
+		seedRegistry.Lock()
+		seedRegistry.seeds = saved
+		seedRegistry.Unlock()
+	})
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "01_countries.sql"), []byte("INSERT INTO countries VALUES ('JP');\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, db := newRecordingMigration(config.CmdConfig{SeedDir: dir, SeedResetTables: []string{"countries", "ref.currencies"}})
+	m.config.Env = "test"
+	store := &recordingSeedStore{db: db, applied: map[string]bool{"01_countries": true}}
+	m.seedStore = store
+
+	assert.NoError(t, m.Seed(false, false))
+	assert.Equal(t, []string{"BEGIN", "COMMIT"}, db.entries())
+
+	db.log = nil
+	assert.NoError(t, m.Seed(true, false))
+	assert.Equal(t, []string{
+		"BEGIN",
+		`TRUNCATE "countries", "ref"."currencies" RESTART IDENTITY`,
+		"remove seed records",
+		"INSERT INTO countries VALUES ('JP')",
+		"record seed 01_countries",
+		"COMMIT",
+	}, db.entries())
+
+	// Without tables to clear, reset runs the seeds over the existing data.
+	m.config.Command.SeedResetTables = nil
+	db.log = nil
+	assert.NoError(t, m.Seed(true, false))
+	assert.Equal(t, []string{
+		"BEGIN",
+		"remove seed records",
+		"INSERT INTO countries VALUES ('JP')",
+		"record seed 01_countries",
+		"COMMIT",
+	}, db.entries())
+}
+
+func TestMigration_Seed_NotConfigured(t *testing.T) {
+	m := &Migration{}
+
+	assert.EqualError(t, m.Seed(false, false), "seed directory is not configured")
+}
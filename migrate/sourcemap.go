@@ -0,0 +1,122 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// sourceMap records, for every line of the SQL of a migration once its
+// includes are expanded and its template variables substituted, the file and
+// line it comes from, so that positions are reported against the files the
+// author edits. A nil sourceMap maps every position to itself.
+type sourceMap []sourceLine
+
+type sourceLine struct {
+	file string
+	line int
+	// edits are the substitutions made on the line, by rendered column.
+	edits []lineEdit
+}
+
+// lineEdit is a substitution on a line: the runes of the rendered line from
+// column on replace those of the source line from sourceColumn on. Columns
+// are 1-based and counted in runes.
+type lineEdit struct {
+	column       int
+	rendered     int
+	sourceColumn int
+	source       int
+}
+
+// newSourceMap maps every line of content to the same line of file.
+func newSourceMap(file string, content string) sourceMap {
+	n := strings.Count(content, "\n") + 1
+	sm := make(sourceMap, n)
+	for i := range sm {
+		sm[i] = sourceLine{file: file, line: i + 1}
+	}
+	return sm
+}
+
+// origin returns the file, line and column that the 1-based line and column
+// of the mapped content come from. file is empty when the map doesn't cover
+// line.
+func (sm sourceMap) origin(line int, column int) (string, int, int) {
+	if line < 1 || line > len(sm) {
+		return "", line, column
+	}
+	l := sm[line-1]
+	delta := 0
+	for _, e := range l.edits {
+		if column < e.column {
+			break
+		}
+		if column < e.column+e.rendered {
+			// Inside substituted text: point at the variable reference.
+			return l.file, l.line, e.sourceColumn
+		}
+		delta = e.sourceColumn + e.source - e.column - e.rendered
+	}
+	return l.file, l.line, column + delta
+}
+
+// renderLines substitutes the matches of pattern line by line with the text
+// replace returns, extending sm to the rendered content. Replacements may
+// span several lines.
+func renderLines(content string, sm sourceMap, pattern *regexp.Regexp, replace func(match string) string) (string, sourceMap) {
+	lines := strings.Split(content, "\n")
+	var out strings.Builder
+	var rendered sourceMap
+	for i, line := range lines {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		current := sourceLine{line: i + 1}
+		if i < len(sm) {
+			current = sourceLine{file: sm[i].file, line: sm[i].line}
+		}
+
+		matches := pattern.FindAllStringIndex(line, -1)
+		if len(matches) == 0 {
+			out.WriteString(line)
+			rendered = append(rendered, current)
+			continue
+		}
+
+		column, last := 1, 0
+		for _, match := range matches {
+			before := line[last:match[0]]
+			out.WriteString(before)
+			column += utf8.RuneCountInString(before)
+			sourceColumn := utf8.RuneCountInString(line[:match[0]]) + 1
+			source := utf8.RuneCountInString(line[match[0]:match[1]])
+
+			parts := strings.Split(replace(line[match[0]:match[1]]), "\n")
+			for j, part := range parts {
+				if j > 0 {
+					out.WriteByte('\n')
+					rendered = append(rendered, current)
+					current = sourceLine{file: current.file, line: current.line}
+					column = 1
+				}
+				out.WriteString(part)
+				width := utf8.RuneCountInString(part)
+				current.edits = append(current.edits, lineEdit{column: column, rendered: width, sourceColumn: sourceColumn, source: source})
+				column += width
+			}
+			last = match[1]
+		}
+		out.WriteString(line[last:])
+		rendered = append(rendered, current)
+	}
+	return out.String(), rendered
+}
+
+// attach makes the positions of statements, split from the mapped content,
+// resolve through sm.
+func (sm sourceMap) attach(statements []Statement) {
+	for i := range statements {
+		statements[i].source = sm
+	}
+}
@@ -0,0 +1,286 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	directiveStatementBegin = "statement-begin"
+	directiveStatementEnd   = "statement-end"
+)
+
+// Statement is a single SQL statement taken from a migration file.
+type Statement struct {
+	SQL string
+	// Line and Column locate the first character of the statement in the file, 1-based.
+	Line   int
+	Column int
+
+	// source maps Line and Column, which count in the expanded and rendered
+	// content, back to the files the statement comes from.
+	source sourceMap
+}
+
+// SplitStatements splits the content of a migration file into statements
+// according to the lexical rules of the given dialect. Text between
+// "-- migrate:statement-begin" and "-- migrate:statement-end" lines is kept
+// as a single statement without being split.
+func SplitStatements(dialect string, content string) ([]Statement, error) {
+	switch dialect {
+	case "", "postgres":
+		s := &postgresSplitter{src: content, line: 1}
+		return s.split()
+	default:
+		return nil, fmt.Errorf("statement splitting is not supported for dialect %q", dialect)
+	}
+}
+
+type postgresSplitter struct {
+	src        string
+	pos        int
+	line       int
+	lineStart  int
+	stmtStart  int
+	stmtLine   int
+	stmtColumn int
+	inStmt     bool
+	statements []Statement
+}
+
+func (s *postgresSplitter) split() ([]Statement, error) {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\n':
+			s.advance(s.pos + 1)
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			s.pos++
+		case c == '-' && s.peek(1) == '-':
+			if err := s.lineComment(); err != nil {
+				return nil, err
+			}
+		case c == '/' && s.peek(1) == '*':
+			if err := s.blockComment(); err != nil {
+				return nil, err
+			}
+		case c == '\'':
+			s.mark()
+			if err := s.quoted('\'', s.isEscapeString()); err != nil {
+				return nil, err
+			}
+		case c == '"':
+			s.mark()
+			if err := s.quoted('"', false); err != nil {
+				return nil, err
+			}
+		case c == '$':
+			s.mark()
+			if err := s.dollarQuoted(); err != nil {
+				return nil, err
+			}
+		case c == ';':
+			s.flush(s.pos)
+			s.pos++
+		default:
+			s.mark()
+			s.pos++
+		}
+	}
+	s.flush(len(s.src))
+	return s.statements, nil
+}
+
+func (s *postgresSplitter) peek(offset int) byte {
+	if s.pos+offset >= len(s.src) {
+		return 0
+	}
+	return s.src[s.pos+offset]
+}
+
+// advance moves to end, keeping track of line numbers on the way.
+func (s *postgresSplitter) advance(end int) {
+	for i := s.pos; i < end; i++ {
+		if s.src[i] == '\n' {
+			s.line++
+			s.lineStart = i + 1
+		}
+	}
+	s.pos = end
+}
+
+// mark records the current position as the start of a statement unless one
+// is already in progress.
+func (s *postgresSplitter) mark() {
+	if s.inStmt {
+		return
+	}
+	s.inStmt = true
+	s.stmtStart = s.pos
+	s.stmtLine = s.line
+	s.stmtColumn = s.pos - s.lineStart + 1
+}
+
+func (s *postgresSplitter) flush(end int) {
+	if !s.inStmt {
+		return
+	}
+	s.inStmt = false
+	sql := strings.TrimSpace(s.src[s.stmtStart:end])
+	if sql == "" {
+		return
+	}
+	s.statements = append(s.statements, Statement{SQL: sql, Line: s.stmtLine, Column: s.stmtColumn})
+}
+
+func (s *postgresSplitter) lineComment() error {
+	end := strings.IndexByte(s.src[s.pos:], '\n')
+	if end < 0 {
+		end = len(s.src)
+	} else {
+		end += s.pos
+	}
+
+	name, _, ok := parseDirective(s.src[s.pos:end])
+	if ok && strings.TrimSpace(s.src[s.lineStart:s.pos]) == "" {
+		switch name {
+		case directiveStatementBegin:
+			return s.statementBlock(end)
+		case directiveStatementEnd:
+			return fmt.Errorf("line %d: %s%s without a matching %s", s.line, directivePrefix, directiveStatementEnd, directiveStatementBegin)
+		}
+	}
+
+	s.pos = end
+	return nil
+}
+
+// statementBlock consumes everything up to the matching statement-end line
+// as a single statement. directiveEnd is the end of the statement-begin line.
+func (s *postgresSplitter) statementBlock(directiveEnd int) error {
+	if s.inStmt {
+		return fmt.Errorf("line %d: %s%s inside an unterminated statement starting at line %d", s.line, directivePrefix, directiveStatementBegin, s.stmtLine)
+	}
+	beginLine := s.line
+	s.advance(directiveEnd)
+
+	for s.pos < len(s.src) {
+		lineEnd := strings.IndexByte(s.src[s.pos:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(s.src)
+		} else {
+			lineEnd += s.pos
+		}
+
+		text := s.src[s.pos:lineEnd]
+		if name, _, ok := parseDirective(text); ok && name == directiveStatementEnd {
+			s.flush(s.pos)
+			s.pos = lineEnd
+			return nil
+		}
+		if strings.TrimSpace(text) != "" {
+			s.pos += len(text) - len(strings.TrimLeft(text, " \t\r"))
+			s.mark()
+		}
+		s.advance(lineEnd)
+		if s.pos < len(s.src) {
+			s.advance(s.pos + 1)
+		}
+	}
+
+	return fmt.Errorf("line %d: %s%s without a matching %s", beginLine, directivePrefix, directiveStatementBegin, directiveStatementEnd)
+}
+
+func (s *postgresSplitter) blockComment() error {
+	startLine := s.line
+	depth := 0
+	i := s.pos
+	for i < len(s.src) {
+		switch {
+		case s.src[i] == '/' && i+1 < len(s.src) && s.src[i+1] == '*':
+			depth++
+			i += 2
+		case s.src[i] == '*' && i+1 < len(s.src) && s.src[i+1] == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				s.advance(i)
+				return nil
+			}
+		default:
+			i++
+		}
+	}
+	return fmt.Errorf("line %d: unterminated block comment", startLine)
+}
+
+// isEscapeString reports whether the quote at the current position opens an
+// E'...' string, in which backslash escapes the next character.
+func (s *postgresSplitter) isEscapeString() bool {
+	if s.pos == 0 || (s.src[s.pos-1] != 'E' && s.src[s.pos-1] != 'e') {
+		return false
+	}
+	return s.pos == 1 || !isIdentifierChar(s.src[s.pos-2])
+}
+
+func (s *postgresSplitter) quoted(quote byte, backslashEscapes bool) error {
+	startLine := s.line
+	i := s.pos + 1
+	for i < len(s.src) {
+		switch {
+		case backslashEscapes && s.src[i] == '\\':
+			i += 2
+		case s.src[i] == quote && i+1 < len(s.src) && s.src[i+1] == quote:
+			i += 2
+		case s.src[i] == quote:
+			s.advance(i + 1)
+			return nil
+		default:
+			i++
+		}
+	}
+	if quote == '"' {
+		return fmt.Errorf("line %d: unterminated quoted identifier", startLine)
+	}
+	return fmt.Errorf("line %d: unterminated string literal", startLine)
+}
+
+// dollarQuoted consumes a $tag$...$tag$ string. A '$' that doesn't open one,
+// such as a positional parameter or one inside an identifier, is consumed as
+// a single character.
+func (s *postgresSplitter) dollarQuoted() error {
+	if s.pos > 0 && isIdentifierChar(s.src[s.pos-1]) {
+		s.pos++
+		return nil
+	}
+	i := s.pos + 1
+	if i < len(s.src) && s.src[i] != '$' {
+		if !isIdentifierStart(s.src[i]) {
+			s.pos++
+			return nil
+		}
+		for i < len(s.src) && isIdentifierChar(s.src[i]) && s.src[i] != '$' {
+			i++
+		}
+	}
+	if i >= len(s.src) || s.src[i] != '$' {
+		s.pos++
+		return nil
+	}
+
+	tag := s.src[s.pos : i+1]
+	end := strings.Index(s.src[i+1:], tag)
+	if end < 0 {
+		return fmt.Errorf("line %d: unterminated dollar-quoted string %s", s.line, tag)
+	}
+	s.advance(i + 1 + end + len(tag))
+	return nil
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
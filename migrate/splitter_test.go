@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Statement
+		wantErr bool
+	}{
+		{
+			name:    "single statement without semicolon",
+			content: "SELECT 1",
+			want:    []Statement{{SQL: "SELECT 1", Line: 1, Column: 1}},
+		},
+		{
+			name:    "multiple statements on one line",
+			content: "SELECT 1; SELECT 2;",
+			want: []Statement{
+				{SQL: "SELECT 1", Line: 1, Column: 1},
+				{SQL: "SELECT 2", Line: 1, Column: 11},
+			},
+		},
+		{
+			name:    "leading comments are skipped",
+			content: "-- Migration\n/* block; comment */\n\nCREATE TABLE a (id INT);\n",
+			want:    []Statement{{SQL: "CREATE TABLE a (id INT)", Line: 4, Column: 1}},
+		},
+		{
+			name:    "nested block comment",
+			content: "/* outer /* inner; */ still comment; */ SELECT 1;",
+			want:    []Statement{{SQL: "SELECT 1", Line: 1, Column: 41}},
+		},
+		{
+			name:    "semicolons inside literals and identifiers",
+			content: "INSERT INTO \"a;b\" VALUES ('x;''y', E'\\';');\nSELECT 2;",
+			want: []Statement{
+				{SQL: "INSERT INTO \"a;b\" VALUES ('x;''y', E'\\';')", Line: 1, Column: 1},
+				{SQL: "SELECT 2", Line: 2, Column: 1},
+			},
+		},
+		{
+			name: "dollar quoted function body",
+			content: `CREATE FUNCTION f() RETURNS trigger AS $body$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT $$;$$, $1;`,
+			want: []Statement{
+				{SQL: "CREATE FUNCTION f() RETURNS trigger AS $body$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql", Line: 1, Column: 1},
+				{SQL: "SELECT $$;$$, $1", Line: 7, Column: 1},
+			},
+		},
+		{
+			name: "statement block",
+			content: `SELECT 1;
+-- migrate:statement-begin
+DO 'BEGIN; END';
+SELECT 2;
+-- migrate:statement-end
+SELECT 3;`,
+			want: []Statement{
+				{SQL: "SELECT 1", Line: 1, Column: 1},
+				{SQL: "DO 'BEGIN; END';\nSELECT 2;", Line: 3, Column: 1},
+				{SQL: "SELECT 3", Line: 6, Column: 1},
+			},
+		},
+		{
+			name:    "unterminated statement block",
+			content: "-- migrate:statement-begin\nSELECT 1;",
+			wantErr: true,
+		},
+		{
+			name:    "statement end without begin",
+			content: "SELECT 1;\n-- migrate:statement-end\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated string",
+			content: "SELECT 'abc;",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated dollar quote",
+			content: "SELECT $x$abc;",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitStatements("postgres", tt.content)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitStatements_UnsupportedDialect(t *testing.T) {
+	_, err := SplitStatements("oracle", "SELECT 1")
+	assert.Error(t, err)
+}
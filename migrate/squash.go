@@ -0,0 +1,187 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// directiveBaseline marks a migration written by Squash. Its applied-version
+// directives list the versions it replaces.
+const directiveBaseline = "baseline"
+
+// squashedVersions returns the versions a baseline migration replaces, or
+// nil when file is not a baseline.
+func (m *Migration) squashedVersions(file MigrationFile) ([]string, error) {
+	if file.IsGo() {
+		return nil, nil
+	}
+	content, err := m.readContent(file)
+	if err != nil {
+		return nil, err
+	}
+	if !hasDirective(content, directiveBaseline) {
+		return nil, nil
+	}
+	return appliedVersions(content), nil
+}
+
+// checkBaseline refuses to apply a baseline to a database that applied only
+// some of the migrations it replaces, since it would create objects that
+// already exist. A database that applied all of them has the version of the
+// baseline and never runs it.
+func (m *Migration) checkBaseline(file MigrationFile, squashed []string) error {
+	var applied []string
+	for _, version := range squashed {
+		ok, err := m.IsMigrationApplied(version)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if ok {
+			applied = append(applied, version)
+		}
+	}
+	if len(applied) > 0 {
+		return fmt.Errorf("baseline %s replaces migrations of which the database applied only %s; "+
+			"apply the others from the original files before upgrading past the squash", file.Path, strings.Join(applied, ", "))
+	}
+	return nil
+}
+
+// dataChangePattern matches the statements that change data rather than
+// the schema.
+var dataChangePattern = regexp.MustCompile(`^(?:INSERT|UPDATE|DELETE|MERGE|COPY)\b|^WITH\b.*\b(?:INSERT|UPDATE|DELETE|MERGE)\b`)
+
+// unsquashable lists the parts of files a baseline can't carry over: template
+// variables, whose values the schema of the scratch database bakes in, and
+// statements changing data, which the baseline loses.
+func (m *Migration) unsquashable(files []MigrationFile) ([]string, error) {
+	var found []string
+	for _, file := range files {
+		content, sm, err := m.readSource(file)
+		if err != nil {
+			return nil, err
+		}
+		if m.templated(file, content, sm) && usesVariables(content) {
+			found = append(found, file.Path+": uses template variables")
+		}
+
+		rendered, renderedMap, err := m.render(file, content, sm)
+		if err != nil {
+			return nil, err
+		}
+		statements, err := SplitStatements(m.dialect(), rendered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split migration file %s: %w", file.Path, err)
+		}
+		renderedMap.attach(statements)
+		for _, stmt := range statements {
+			if dataChangePattern.MatchString(strings.ToUpper(blankLiterals(stmt.SQL))) {
+				path, line, _ := stmt.origin(file.Path, stmt.Line, stmt.Column)
+				found = append(found, fmt.Sprintf("%s:%d: changes data: %s", path, line, oneLine(stmt.SQL)))
+			}
+		}
+	}
+	return found, nil
+}
+
+// usesVariables reports whether content references a template variable,
+// escaped references aside.
+func usesVariables(content string) bool {
+	for _, match := range templateVariablePattern.FindAllString(content, -1) {
+		if !strings.HasPrefix(match, "$$") {
+			return true
+		}
+	}
+	return false
+}
+
+// Squash replaces the migrations at or below version before with a single
+// baseline migration holding the schema they produce, built in a scratch
+// database, and returns its path. The baseline takes the highest squashed
+// version, so databases that applied the originals consider it applied,
+// while fresh databases run it and record every squashed version. It
+// refuses migrations using template variables or changing data, which the
+// baseline can't reproduce, unless force is set.
+func (m *Migration) Squash(before string, force bool) (string, error) {
+	var squashed []MigrationFile
+	var goMigrations []string
+	for _, file := range m.UpFiles {
+		if file.Version() > before {
+			continue
+		}
+		if file.IsGo() {
+			goMigrations = append(goMigrations, file.Version())
+		}
+		squashed = append(squashed, file)
+	}
+	if len(squashed) == 0 {
+		return "", fmt.Errorf("no migrations at or below version %s to squash", before)
+	}
+	if len(goMigrations) > 0 {
+		return "", fmt.Errorf("can't squash Go migrations %s; remove their Register calls or squash below them", strings.Join(goMigrations, ", "))
+	}
+	found, err := m.unsquashable(squashed)
+	if err != nil {
+		return "", err
+	}
+	if len(found) > 0 {
+		if !force {
+			lines := []string{"the baseline can't reproduce the following, refusing to squash without force:"}
+			for _, f := range found {
+				lines = append(lines, "  "+f)
+			}
+			return "", errors.New(strings.Join(lines, "\n"))
+		}
+		for _, f := range found {
+			m.logf("Squashing despite %s", f)
+		}
+	}
+	version := squashed[len(squashed)-1].Version()
+
+	var schema *Schema
+	err = m.withScratchDatabase(func(scratch *Migration) error {
+		scratch.UpFiles = squashed
+		scratch.RepeatableFiles = nil
+		if err := scratch.Up(); err != nil {
+			return fmt.Errorf("failed to migrate scratch database: %w", err)
+		}
+		var err error
+		schema, err = scratch.InspectSchema()
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	content := fmt.Sprintf("%s%s\n-- Squashes %d migrations up to %s.\n\n%s",
+		directivePrefix, directiveBaseline, len(squashed), version, schema.SQL())
+
+	// The baseline is written next to the originals before they are
+	// removed, so a failure leaves them in place.
+	dir := m.config.Command.MigrationDir
+	tmp := filepath.Join(dir, fmt.Sprintf(".%s_baseline.squash", version))
+	if err := writeNewFile(tmp, []byte(content)); err != nil {
+		return "", err
+	}
+	for _, file := range squashed {
+		if down := m.FindFileByVersion(file.Version(), "down"); down != nil {
+			if err := os.Remove(down.Path); err != nil {
+				return "", fmt.Errorf("failed to remove squashed migration: %w", err)
+			}
+		}
+		if err := os.Remove(file.Path); err != nil {
+			return "", fmt.Errorf("failed to remove squashed migration: %w", err)
+		}
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_baseline.up.sql", version))
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to write baseline migration: %w", err)
+	}
+
+	m.logf("Squashed %d migrations into %s", len(squashed), path)
+	return path, nil
+}
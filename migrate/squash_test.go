@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSchemaReader struct {
+	applied map[string]bool
+	batches map[int][]string
+}
+
+func (r *mockSchemaReader) ListAppliedMigrations() ([]SchemaMigration, error) { return nil, nil }
+func (r *mockSchemaReader) IsMigrationApplied(version string) (bool, error) {
+	return r.applied[version], nil
+}
+func (r *mockSchemaReader) GetLastBatch() (int, error) {
+	last := 0
+	for batch, versions := range r.batches {
+		if len(versions) > 0 {
+			last = max(last, batch)
+		}
+	}
+	return last, nil
+}
+func (r *mockSchemaReader) ListBatchVersions(batch int) ([]string, error) {
+	return r.batches[batch], nil
+}
+func (r *mockSchemaReader) ListRepeatableMigrations() ([]SchemaMigration, error) { return nil, nil }
+
+func TestMigration_Baseline(t *testing.T) {
+	dir := t.TempDir()
+	baseline := MigrationFile{Path: filepath.Join(dir, "0003_baseline.up.sql"), Kind: "up"}
+	schema := &Schema{Tables: []Table{{Name: "public.users"}}, Versions: []string{"0001", "0002", "0003"}}
+	content := directivePrefix + directiveBaseline + "\n" + schema.SQL()
+	if err := os.WriteFile(baseline.Path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write baseline: %v", err)
+	}
+	regular := MigrationFile{Path: filepath.Join(dir, "0004_add_email.up.sql"), Kind: "up"}
+	if err := os.WriteFile(regular.Path, []byte("-- migrate:applied-version 0001\nSELECT 1;"), 0o644); err != nil {
+		t.Fatalf("failed to write migration: %v", err)
+	}
+
+	reader := &mockSchemaReader{applied: map[string]bool{}}
+	m := &Migration{schemaReader: reader, config: &config.Config{Command: config.CmdConfig{MigrationDir: dir}}}
+
+	squashed, err := m.squashedVersions(baseline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0001", "0002", "0003"}, squashed)
+	squashed, err = m.squashedVersions(regular)
+	assert.NoError(t, err)
+	assert.Nil(t, squashed)
+
+	assert.NoError(t, m.checkBaseline(baseline, []string{"0001", "0002", "0003"}))
+	reader.applied["0001"] = true
+	reader.applied["0002"] = true
+	assert.ErrorContains(t, m.checkBaseline(baseline, []string{"0001", "0002", "0003"}), "the database applied only 0001, 0002;")
+}
+
+func TestMigration_SquashNothing(t *testing.T) {
+	m := &Migration{UpFiles: []MigrationFile{{Path: "0005_users.up.sql", Kind: "up"}}}
+	_, err := m.Squash("0004", false)
+	assert.EqualError(t, err, "no migrations at or below version 0004 to squash")
+}
+
+func TestMigration_SquashUnsquashable(t *testing.T) {
+	dir := t.TempDir()
+	m := &Migration{
+		UpFiles: []MigrationFile{
+			writeMigrationFile(t, dir, "0001_users.up.sql", "CREATE TABLE users (id INT, role TEXT DEFAULT '${role}');"),
+			writeMigrationFile(t, dir, "0002_grant.up.sql", "-- migrate:template\nGRANT SELECT ON users TO ${role};\nSELECT '$${escaped}';"),
+			writeMigrationFile(t, dir, "0003_admin.up.sql", "CREATE TABLE roles (name TEXT);\n\nINSERT INTO roles VALUES ('admin');\n"+
+				"WITH old AS (SELECT 1) DELETE FROM roles WHERE name = 'guest';"),
+		},
+		config: &config.Config{Command: config.CmdConfig{MigrationDir: dir, Vars: map[string]string{"role": "reader"}}},
+	}
+
+	_, err := m.Squash("0003", false)
+	assert.EqualError(t, err, "the baseline can't reproduce the following, refusing to squash without force:\n"+
+		"  "+m.UpFiles[1].Path+": uses template variables\n"+
+		"  "+m.UpFiles[2].Path+":3: changes data: INSERT INTO roles VALUES ('admin')\n"+
+		"  "+m.UpFiles[2].Path+":4: changes data: WITH old AS (SELECT 1) DELETE FROM roles WHERE name = 'guest'")
+
+	found, err := m.unsquashable(m.UpFiles[:1])
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// directiveTemplate enables variable substitution for a single file when it
+// isn't enabled for every file through config.CmdConfig.Template.
+const directiveTemplate = "template"
+
+// envVarPrefix marks environment variables that are template variables:
+// MIGRATE_VAR_tablespace sets ${tablespace}.
+const envVarPrefix = "MIGRATE_VAR_"
+
+// templateVariablePattern matches ${name}, and $${name} which renders as a
+// literal ${name}.
+var templateVariablePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_.]*)\}`)
+
+// renderTemplate replaces every ${name} in content with its value in vars.
+// Every variable missing from vars is reported in a single error.
+func renderTemplate(content string, vars map[string]string) (string, error) {
+	rendered, _, err := renderTemplateMapped(content, nil, vars)
+	return rendered, err
+}
+
+// renderTemplateMapped is renderTemplate for content mapped by sm, returning
+// the map of the rendered content.
+func renderTemplateMapped(content string, sm sourceMap, vars map[string]string) (string, sourceMap, error) {
+	missing := map[string]bool{}
+	rendered, renderedMap := renderLines(content, sm, templateVariablePattern, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		name := match[2 : len(match)-1]
+		value, ok := vars[name]
+		if !ok {
+			missing[name] = true
+			return match
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", nil, fmt.Errorf("undefined template variable(s): %s", strings.Join(names, ", "))
+	}
+	return rendered, renderedMap, nil
+}
+
+// SetVars sets template variables taking precedence over the configuration
+// and the environment, typically those given with --var on the command line.
+func (m *Migration) SetVars(vars map[string]string) {
+	m.vars = vars
+}
+
+// templateVars merges, from lowest to highest precedence, the configured
+// variables, the MIGRATE_VAR_* environment variables and those set with SetVars.
+func (m *Migration) templateVars() map[string]string {
+	vars := map[string]string{}
+	if m.config != nil {
+		for name, value := range m.config.Command.Vars {
+			vars[name] = value
+		}
+	}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, envVarPrefix) {
+			vars[strings.TrimPrefix(name, envVarPrefix)] = value
+		}
+	}
+	for name, value := range m.vars {
+		vars[name] = value
+	}
+	return vars
+}
+
+// templated reports whether the variables of file are substituted: when
+// templating is enabled for every file, or for this one by a directive in its
+// header. content is the SQL of file with its includes expanded, mapped by
+// sm, so that a directive coming from an included fragment is ignored.
+func (m *Migration) templated(file MigrationFile, content string, sm sourceMap) bool {
+	if m.config != nil && m.config.Command.Template {
+		return true
+	}
+	for _, d := range headerDirectives(content) {
+		own := d.line > len(sm) || sm[d.line-1].file == file.Path
+		if d.name == directiveTemplate && own {
+			return true
+		}
+	}
+	return false
+}
+
+// render substitutes the template variables of content, mapped by sm, when
+// it is templated.
+func (m *Migration) render(file MigrationFile, content string, sm sourceMap) (string, sourceMap, error) {
+	if !m.templated(file, content, sm) {
+		return content, sm, nil
+	}
+
+	rendered, renderedMap, err := renderTemplateMapped(content, sm, m.templateVars())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to render migration file %s: %w", file.Path, err)
+	}
+	return rendered, renderedMap, nil
+}
+
+// Render returns the SQL of file as it will be executed.
+func (m *Migration) Render(file MigrationFile) (string, error) {
+	if file.IsGo() {
+		return "", fmt.Errorf("%s is a Go migration and has no SQL", file.Path)
+	}
+	content, _, err := m.renderSource(file)
+	return content, err
+}
+
+// renderSource returns the SQL of file as it will be executed, with the map
+// of its lines back to the files they come from.
+func (m *Migration) renderSource(file MigrationFile) (string, sourceMap, error) {
+	content, sm, err := m.readSource(file)
+	if err != nil {
+		return "", nil, err
+	}
+	return m.render(file, content, sm)
+}
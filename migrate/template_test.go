@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{"role": "app_reader", "tenant.schema": "acme"}
+
+	got, err := renderTemplate("GRANT SELECT ON ${tenant.schema}.users TO ${role};", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "GRANT SELECT ON acme.users TO app_reader;", got)
+
+	got, err = renderTemplate("SELECT '$${role}', $1, $$body$$;", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT '${role}', $1, $$body$$;", got)
+
+	_, err = renderTemplate("CREATE TABLE t () TABLESPACE ${tablespace} ${owner} ${tablespace};", vars)
+	assert.EqualError(t, err, "undefined template variable(s): owner, tablespace")
+}
+
+func TestMigration_Render(t *testing.T) {
+	dir := t.TempDir()
+	plain := writeMigrationFile(t, dir, "20250101_plain.up.sql", "SELECT '${role}';")
+	optIn := writeMigrationFile(t, dir, "20250102_opt_in.up.sql", "-- migrate:template\nGRANT ALL ON t TO ${role};")
+
+	t.Setenv(envVarPrefix+"role", "from_env")
+	m := &Migration{config: &config.Config{Command: config.CmdConfig{Vars: map[string]string{"role": "from_config"}}}}
+
+	got, err := m.Render(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT '${role}';", got)
+
+	got, err = m.Render(optIn)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- migrate:template\nGRANT ALL ON t TO from_env;", got)
+
+	m.SetVars(map[string]string{"role": "from_cli"})
+	m.config.Command.Template = true
+	got, err = m.Render(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT 'from_cli';", got)
+}
+
+func TestMigration_RenderHeaderDirective(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared")
+	assert.NoError(t, os.MkdirAll(shared, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(shared, "grants.sql"), []byte("-- migrate:template\nGRANT ALL ON t TO ${role};\n"), 0o644))
+	m := &Migration{config: &config.Config{Command: config.CmdConfig{
+		MigrationDir: dir,
+		SharedDir:    shared,
+		Vars:         map[string]string{"role": "app"},
+	}}}
+
+	// A fragment can't enable templating for the file including it.
+	included := writeMigrationFile(t, dir, "20250101_included.up.sql", "-- migrate:include grants.sql\nSELECT '${role}';\n")
+	got, err := m.Render(included)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- migrate:template\nGRANT ALL ON t TO ${role};\nSELECT '${role}';\n", got)
+
+	// Nor can a directive after the first statement.
+	late := writeMigrationFile(t, dir, "20250102_late.up.sql", "SELECT '${role}';\n-- migrate:template\n")
+	got, err = m.Render(late)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT '${role}';\n-- migrate:template\n", got)
+
+	header := writeMigrationFile(t, dir, "20250103_header.up.sql", "-- Grants for the app role.\n\n-- migrate:template\n-- migrate:include grants.sql\n")
+	got, err = m.Render(header)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- Grants for the app role.\n\n-- migrate:template\n-- migrate:template\nGRANT ALL ON t TO app;\n", got)
+}
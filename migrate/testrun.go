@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// nonTransactionalPatterns match the statements that can't run inside a
+// transaction, or that would end the one a migration runs in.
+var nonTransactionalPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?:CREATE\s+(?:UNIQUE\s+)?|DROP\s+)INDEX\s+CONCURRENTLY\b`),
+	regexp.MustCompile(`^REINDEX\b.*\bCONCURRENTLY\b`),
+	regexp.MustCompile(`^(?:CREATE|DROP)\s+(?:DATABASE|TABLESPACE)\b`),
+	regexp.MustCompile(`^(?:VACUUM|ALTER\s+SYSTEM|CREATE\s+SUBSCRIPTION)\b`),
+	regexp.MustCompile(`^(?:BEGIN|START\s+TRANSACTION|COMMIT|END|ROLLBACK|ABORT)\b`),
+}
+
+// NonTransactionalStatement is a statement of a migration file that can't run
+// inside a transaction.
+type NonTransactionalStatement struct {
+	File string
+	Line int
+	SQL  string
+}
+
+func (s NonTransactionalStatement) String() string {
+	return fmt.Sprintf("%s:%d: %s", s.File, s.Line, oneLine(s.SQL))
+}
+
+// NonTransactionalStatements returns the statements of the SQL files that
+// can't run inside a transaction.
+func (m *Migration) NonTransactionalStatements(files []MigrationFile) ([]NonTransactionalStatement, error) {
+	var found []NonTransactionalStatement
+	for _, file := range files {
+		if file.IsGo() {
+			continue
+		}
+		statements, err := m.readStatements(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range statements {
+			sql := strings.ToUpper(blankLiterals(stmt.SQL))
+			for _, pattern := range nonTransactionalPatterns {
+				if pattern.MatchString(sql) {
+					path, line, _ := stmt.origin(file.Path, stmt.Line, stmt.Column)
+					found = append(found, NonTransactionalStatement{File: path, Line: line, SQL: stmt.SQL})
+					break
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+// TestUp runs the pending migrations like Up, then rolls the transaction back
+// instead of committing it, leaving the database as it was. It refuses to run
+// when a pending migration has statements that can't run inside a
+// transaction, since they would take effect for good.
+func (m *Migration) TestUp(opts ...PreflightOption) error {
+	pending, err := m.PendingFiles()
+	if err != nil {
+		return err
+	}
+	found, err := m.NonTransactionalStatements(pending)
+	if err != nil {
+		return err
+	}
+	if len(found) > 0 {
+		lines := []string{"refusing to test-run migrations that can't be rolled back:"}
+		for _, stmt := range found {
+			lines = append(lines, "  "+stmt.String())
+		}
+		return errors.New(strings.Join(lines, "\n"))
+	}
+
+	started := time.Now()
+	timings, err := m.runPending(false, opts)
+	if err != nil {
+		m.logf("Test run failed after %s, rolled back", time.Since(started))
+		return err
+	}
+	m.logf("Test run applied %d migration(s) in %s and rolled them back:", len(timings), time.Since(started))
+	for _, timing := range timings {
+		m.logf("  %s took %s", timing.file.Path, timing.took)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigration_TestUpRefusesNonTransactional(t *testing.T) {
+	dir := t.TempDir()
+	m := &Migration{
+		UpFiles: []MigrationFile{
+			writeMigrationFile(t, dir, "20250101_users.up.sql", "CREATE INDEX CONCURRENTLY users_email ON users (email);\n"),
+			writeMigrationFile(t, dir, "20250102_email.up.sql", "ALTER TABLE users ADD email TEXT;\nCREATE INDEX users_email ON users (email);\n"),
+			writeMigrationFile(t, dir, "20250103_vacuum.up.sql", "UPDATE users SET email = 'VACUUM';\n\nVACUUM ANALYZE users;\n"),
+			writeMigrationFile(t, dir, "20250104_commit.up.sql", "INSERT INTO settings VALUES ('a', 1);\nCOMMIT;\n"),
+		},
+		statusGetter: &mockStatusGetter{version: "20250101"},
+		schemaReader: &mockSchemaReader{},
+		config:       &config.Config{Command: config.CmdConfig{MigrationDir: dir}},
+	}
+
+	found, err := m.NonTransactionalStatements(m.UpFiles)
+	assert.NoError(t, err)
+	assert.Equal(t, []NonTransactionalStatement{
+		{File: m.UpFiles[0].Path, Line: 1, SQL: "CREATE INDEX CONCURRENTLY users_email ON users (email)"},
+		{File: m.UpFiles[2].Path, Line: 3, SQL: "VACUUM ANALYZE users"},
+		{File: m.UpFiles[3].Path, Line: 2, SQL: "COMMIT"},
+	}, found)
+
+	err = m.TestUp()
+	assert.EqualError(t, err, "refusing to test-run migrations that can't be rolled back:\n"+
+		"  "+m.UpFiles[2].Path+":3: VACUUM ANALYZE users\n"+
+		"  "+m.UpFiles[3].Path+":2: COMMIT")
+}
+
+func TestMigration_TestUpRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir},
+		writeMigrationFile(t, dir, "20250101_users.up.sql", "CREATE TABLE users (id INT);\n"),
+		writeMigrationFile(t, dir, "20250102_email.up.sql", "ALTER TABLE users ADD email TEXT;\n"))
+
+	var out bytes.Buffer
+	m.SetLogger(log.New(&out, "", 0))
+
+	assert.NoError(t, m.TestUp())
+	assert.Equal(t, []string{
+		"BEGIN",
+		"CREATE TABLE users (id INT)",
+		"record 20250101",
+		"ALTER TABLE users ADD email TEXT",
+		"record 20250102",
+		"ROLLBACK",
+	}, db.entries())
+
+	// Each migration's time is listed after the total.
+	summary := out.String()[strings.Index(out.String(), "Test run applied 2 migration(s) in "):]
+	assert.Regexp(t, `^Test run applied 2 migration\(s\) in \S+ and rolled them back:\n`+
+		`  \S+/20250101_users\.up\.sql took \S+\n`+
+		`  \S+/20250102_email\.up\.sql took \S+\n$`, summary)
+}
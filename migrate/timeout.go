@@ -0,0 +1,226 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Directives overriding the configured timeouts for one file, e.g.
+// "-- migrate:lock-timeout 5s". A value of 0 disables the timeout.
+const (
+	directiveLockTimeout      = "lock-timeout"
+	directiveStatementTimeout = "statement-timeout"
+)
+
+// lockNotAvailable is the SQLSTATE raised when lock_timeout expires.
+const lockNotAvailable = "55P03"
+
+// defaultLockRetryBackoff is the wait before the first retry when none is
+// configured. It doubles after each attempt.
+const defaultLockRetryBackoff = time.Second
+
+// timeouts are the lock_timeout and statement_timeout of a file, in the
+// Postgres format ("5s", "500ms", "0"). Empty values are left unset.
+type timeouts struct {
+	lock      string
+	statement string
+}
+
+func (t timeouts) empty() bool {
+	return t.lock == "" && t.statement == ""
+}
+
+type setting struct {
+	name  string
+	value string
+}
+
+// settings returns the Postgres settings of t that have a value, in a fixed
+// order.
+func (t timeouts) settings() []setting {
+	var settings []setting
+	if t.lock != "" {
+		settings = append(settings, setting{"lock_timeout", t.lock})
+	}
+	if t.statement != "" {
+		settings = append(settings, setting{"statement_timeout", t.statement})
+	}
+	return settings
+}
+
+func (t timeouts) String() string {
+	var parts []string
+	for _, s := range t.settings() {
+		parts = append(parts, s.name+"="+s.value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fileTimeouts returns the configured timeouts, overridden by the directives
+// of file. Go migrations only get the configured ones.
+func (m *Migration) fileTimeouts(file MigrationFile) (timeouts, error) {
+	var t timeouts
+	if m.config != nil {
+		t.lock = m.config.Command.LockTimeout
+		t.statement = m.config.Command.StatementTimeout
+	}
+	if file.IsGo() {
+		return t, nil
+	}
+
+	content, err := m.readContent(file)
+	if err != nil {
+		return t, err
+	}
+	if value, ok := findDirective(content, directiveLockTimeout); ok {
+		if value == "" {
+			return t, fmt.Errorf("%s: %s directive without a value", file.Path, directiveLockTimeout)
+		}
+		t.lock = value
+	}
+	if value, ok := findDirective(content, directiveStatementTimeout); ok {
+		if value == "" {
+			return t, fmt.Errorf("%s: %s directive without a value", file.Path, directiveStatementTimeout)
+		}
+		t.statement = value
+	}
+	return t, nil
+}
+
+// isLockTimeout reports whether err comes from lock_timeout expiring.
+func isLockTimeout(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == lockNotAvailable
+}
+
+// runFile executes file with its timeouts set. Inside tx they are set with
+// SET LOCAL and reset after the file, so that they don't apply to the files
+// after it. Outside a transaction the statements run on a connection of its
+// own, with the timeouts set for the session and reset before the connection
+// goes back to the pool.
+func (m *Migration) runFile(tx *sql.Tx, file MigrationFile) error {
+	t, err := m.fileTimeouts(file)
+	if err != nil {
+		return err
+	}
+	if t.empty() {
+		return m.executeFile(tx, file)
+	}
+
+	m.logf("Running %s with %s", file.Path, t)
+	if tx == nil {
+		return m.runFileAlone(file, t)
+	}
+	if err := m.setTimeouts(tx, true, t); err != nil {
+		return err
+	}
+	if err := m.executeFile(tx, file); err != nil {
+		return err
+	}
+	return m.resetTimeouts(tx, true, t)
+}
+
+// runFileAlone executes file outside a transaction with the timeouts t. Go
+// migrations, which run in a transaction of their own, get them with SET
+// LOCAL.
+func (m *Migration) runFileAlone(file MigrationFile, t timeouts) (err error) {
+	if file.IsGo() {
+		return m.inTransaction(func(tx *sql.Tx) error {
+			if err := m.setTimeouts(tx, true, t); err != nil {
+				return err
+			}
+			return m.executeFile(tx, file)
+		})
+	}
+
+	statements, err := m.readStatements(file)
+	if err != nil {
+		return err
+	}
+	conn, err := m.repo.DB().Conn(m.context())
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.setTimeouts(conn, false, t); err != nil {
+		return err
+	}
+	defer func() {
+		if resetErr := m.resetTimeouts(conn, false, t); resetErr != nil && err == nil {
+			err = resetErr
+		}
+	}()
+	return m.execStatements(conn, file, statements)
+}
+
+// setTimeouts sets the timeouts of t on db, for the transaction when local
+// is set and for the session otherwise.
+func (m *Migration) setTimeouts(db execer, local bool, t timeouts) error {
+	set := "SET"
+	if local {
+		set = "SET LOCAL"
+	}
+	for _, s := range t.settings() {
+		if _, err := db.ExecContext(m.context(), fmt.Sprintf("%s %s = %s", set, s.name, pq.QuoteLiteral(s.value))); err != nil {
+			return fmt.Errorf("failed to set %s to %s: %w", s.name, s.value, err)
+		}
+	}
+	return nil
+}
+
+// resetTimeouts restores the defaults of the timeouts setTimeouts set.
+func (m *Migration) resetTimeouts(db execer, local bool, t timeouts) error {
+	for _, s := range t.settings() {
+		reset := "RESET " + s.name
+		if local {
+			reset = "SET LOCAL " + s.name + " TO DEFAULT"
+		}
+		if _, err := db.ExecContext(m.context(), reset); err != nil {
+			return fmt.Errorf("failed to reset %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// retryLocks calls run again when it fails on a lock timeout, up to
+// LockRetries times, waiting LockRetryBackoff before the first retry and
+// twice as long before each following one. A run in a transaction rolls it
+// back on failure, so that the locks taken by every file of the transaction
+// are released during the wait, and the retry starts over. A file run outside
+// a transaction is run again from its first statement.
+func (m *Migration) retryLocks(what string, run func() error) error {
+	retries, backoff := 0, defaultLockRetryBackoff
+	if m.config != nil {
+		retries = m.config.Command.LockRetries
+		if m.config.Command.LockRetryBackoff > 0 {
+			backoff = m.config.Command.LockRetryBackoff
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || !isLockTimeout(err) {
+			return err
+		}
+		if attempt > retries {
+			if retries > 0 {
+				m.logf("Giving up on %s after %d attempts", what, attempt)
+			}
+			return err
+		}
+
+		m.logf("Lock timeout running %s; retrying in %s (attempt %d of %d): %v", what, backoff, attempt+1, retries+1, err)
+		select {
+		case <-time.After(backoff):
+		case <-m.context().Done():
+			return m.context().Err()
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gooolib/migration/config"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigration_FileTimeouts(t *testing.T) {
+	dir := t.TempDir()
+	m := &Migration{config: &config.Config{Command: config.CmdConfig{MigrationDir: dir, LockTimeout: "5s", StatementTimeout: "1min"}}}
+
+	got, err := m.fileTimeouts(writeMigrationFile(t, dir, "20250101_plain.up.sql", "CREATE TABLE users (id INT);\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, timeouts{lock: "5s", statement: "1min"}, got)
+	assert.Equal(t, "lock_timeout=5s, statement_timeout=1min", got.String())
+
+	got, err = m.fileTimeouts(writeMigrationFile(t, dir, "20250102_index.up.sql", "-- migrate:lock-timeout 500ms\n-- migrate:statement-timeout 0\nCREATE INDEX users_id ON users (id);\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, timeouts{lock: "500ms", statement: "0"}, got)
+
+	_, err = m.fileTimeouts(writeMigrationFile(t, dir, "20250103_empty.up.sql", "-- migrate:lock-timeout\nSELECT 1;\n"))
+	assert.ErrorContains(t, err, "lock-timeout directive without a value")
+
+	got, err = m.fileTimeouts(MigrationFile{Path: filepath.Join(dir, "20250104_backfill.go"), Kind: "up", fn: noopMigration})
+	assert.NoError(t, err)
+	assert.Equal(t, timeouts{lock: "5s", statement: "1min"}, got)
+
+	m.config.Command = config.CmdConfig{MigrationDir: dir}
+	got, err = m.fileTimeouts(writeMigrationFile(t, dir, "20250105_plain.up.sql", "SELECT 1;\n"))
+	assert.NoError(t, err)
+	assert.True(t, got.empty())
+}
+
+func TestIsLockTimeout(t *testing.T) {
+	lockErr := &pq.Error{Code: lockNotAvailable, Message: "canceling statement due to lock timeout"}
+	stmt := Statement{SQL: "ALTER TABLE users ADD email TEXT", Line: 1, Column: 1}
+
+	assert.True(t, isLockTimeout(lockErr))
+	assert.True(t, isLockTimeout(newMigrationError(MigrationFile{Path: "20250101_users.up.sql", Kind: "up"}, 1, stmt, lockErr)))
+	assert.True(t, isLockTimeout(fmt.Errorf("go migration failed: %w", lockErr)))
+	assert.False(t, isLockTimeout(&pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"}))
+	assert.False(t, isLockTimeout(errors.New("lock timeout")))
+}
+
+func TestMigration_UpRetriesLockTimeout(t *testing.T) {
+	dir := t.TempDir()
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir, LockTimeout: "1s", LockRetries: 2, LockRetryBackoff: time.Millisecond},
+		writeMigrationFile(t, dir, "0001_accounts.up.sql", "CREATE TABLE accounts (id INT);\n"),
+		writeMigrationFile(t, dir, "0002_email.up.sql", "-- migrate:lock-timeout 50ms\nALTER TABLE users ADD email TEXT;\n"))
+	failures := 1
+	db.fail = func(query string) error {
+		if strings.HasPrefix(query, "ALTER TABLE users") && failures > 0 {
+			failures--
+			return &pq.Error{Code: lockNotAvailable, Message: "canceling statement due to lock timeout"}
+		}
+		return nil
+	}
+
+	assert.NoError(t, m.Up())
+	attempt := []string{
+		"BEGIN",
+		"SET LOCAL lock_timeout = '1s'",
+		"CREATE TABLE accounts (id INT)",
+		"SET LOCAL lock_timeout TO DEFAULT",
+		"record 0001",
+		"SET LOCAL lock_timeout = '50ms'",
+		"ALTER TABLE users ADD email TEXT",
+	}
+	// The whole transaction is rolled back before the retry, releasing the
+	// lock on accounts along with the others.
+	want := append(append(append([]string{}, attempt...), "ROLLBACK"), attempt...)
+	want = append(want, "SET LOCAL lock_timeout TO DEFAULT", "record 0002", "COMMIT")
+	assert.Equal(t, want, db.entries())
+}
+
+func TestMigration_UpGivesUpOnLockTimeout(t *testing.T) {
+	dir := t.TempDir()
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir, LockTimeout: "1s", LockRetries: 1, LockRetryBackoff: time.Millisecond},
+		writeMigrationFile(t, dir, "0001_email.up.sql", "ALTER TABLE users ADD email TEXT;\n"))
+	db.fail = func(query string) error {
+		if strings.HasPrefix(query, "ALTER") {
+			return &pq.Error{Code: lockNotAvailable, Message: "canceling statement due to lock timeout"}
+		}
+		return nil
+	}
+
+	err := m.Up()
+	assert.True(t, isLockTimeout(err))
+	assert.Equal(t, 2, strings.Count(strings.Join(db.entries(), "\n"), "ROLLBACK"))
+	assert.NotContains(t, db.entries(), "COMMIT")
+}
+
+func TestMigration_RunSingleUpTimeouts(t *testing.T) {
+	dir := t.TempDir()
+	file := writeMigrationFile(t, dir, "0001_email.up.sql", "ALTER TABLE users ADD email TEXT;\nCREATE INDEX users_email ON users (email);\n")
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir, LockTimeout: "1s", StatementTimeout: "1min"}, file)
+
+	assert.NoError(t, m.RunSingleUp(file))
+	assert.Equal(t, []string{
+		"SET lock_timeout = '1s'",
+		"SET statement_timeout = '1min'",
+		"ALTER TABLE users ADD email TEXT",
+		"CREATE INDEX users_email ON users (email)",
+		"RESET lock_timeout",
+		"RESET statement_timeout",
+		"record 0001",
+	}, db.entries())
+}
+
+func TestMigration_RunSingleUpRetriesLockTimeout(t *testing.T) {
+	dir := t.TempDir()
+	file := writeMigrationFile(t, dir, "0001_email_index.up.sql", "CREATE INDEX CONCURRENTLY users_email ON users (email);\n")
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir, LockTimeout: "1s", LockRetries: 1, LockRetryBackoff: time.Millisecond}, file)
+	failures := 1
+	db.fail = func(query string) error {
+		if strings.HasPrefix(query, "CREATE INDEX") && failures > 0 {
+			failures--
+			return &pq.Error{Code: lockNotAvailable, Message: "canceling statement due to lock timeout"}
+		}
+		return nil
+	}
+
+	assert.NoError(t, m.RunSingleUp(file))
+	attempt := []string{
+		"SET lock_timeout = '1s'",
+		"CREATE INDEX CONCURRENTLY users_email ON users (email)",
+		"RESET lock_timeout",
+	}
+	want := append(append(append([]string{}, attempt...), attempt...), "record 0001")
+	assert.Equal(t, want, db.entries())
+}
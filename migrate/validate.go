@@ -0,0 +1,182 @@
+package migrate
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ValidationError describes a single problem found in the migrations directory.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors collects every problem found in the migrations directory.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("%d problem(s) found in migration files:", len(e)))
+	for _, problem := range e {
+		lines = append(lines, "  "+problem.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks every .sql file in dir and returns the problems found.
+// An empty result means the directory can be loaded.
+func Validate(dir string) (ValidationErrors, error) {
+	contents, err := scanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return contents.problems, nil
+}
+
+// dirContents is the result of scanDir.
+type dirContents struct {
+	upFiles         []MigrationFile
+	downFiles       []MigrationFile
+	repeatableFiles []MigrationFile
+	problems        ValidationErrors
+}
+
+// scanDir classifies the .sql files in dir into up and down files, merges
+// them with the registered Go migrations, sorted by version, and reports file
+// names that don't parse, duplicate versions and down files without a
+// matching up file. An up file without a down file is valid: the migration is
+// irreversible. Repeatable migrations are collected separately, sorted by name.
+func scanDir(dir string) (*dirContents, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files: %w", err)
+	}
+
+	sort.Strings(paths)
+
+	repeatableFiles, problems, err := scanRepeatables(dir, paths)
+	if err != nil {
+		return nil, err
+	}
+	paths = slices.DeleteFunc(paths, func(path string) bool {
+		return strings.HasPrefix(filepath.Base(path), "R_")
+	})
+
+	goUpFiles, goDownFiles := registeredFiles()
+	files := make([]MigrationFile, 0, len(paths)+len(goUpFiles)+len(goDownFiles))
+	for _, path := range paths {
+		files = append(files, MigrationFile{Path: path})
+	}
+	files = append(files, goUpFiles...)
+	files = append(files, goDownFiles...)
+
+	var (
+		upFiles   []MigrationFile
+		downFiles []MigrationFile
+	)
+	seen := map[string]map[string]string{"up": {}, "down": {}}
+	names := map[string]string{}
+
+	for _, file := range files {
+		version, name, kind, err := ParseMigrationFileName(filepath.Base(file.Path))
+		if err != nil {
+			problems = append(problems, ValidationError{Path: file.Path, Message: err.Error()})
+			continue
+		}
+
+		if other, ok := seen[kind][version]; ok {
+			problems = append(problems, ValidationError{
+				Path:    file.Path,
+				Message: fmt.Sprintf("duplicate %s migration for version %s (also defined by %s)", kind, version, other),
+			})
+			continue
+		}
+		seen[kind][version] = file.Path
+
+		file.Kind = kind
+		if kind == "up" {
+			names[version] = name
+			upFiles = append(upFiles, file)
+		} else {
+			downFiles = append(downFiles, file)
+		}
+	}
+
+	for _, file := range downFiles {
+		upName, ok := names[file.Version()]
+		if !ok {
+			problems = append(problems, ValidationError{
+				Path:    file.Path,
+				Message: fmt.Sprintf("down migration has no matching up migration for version %s", file.Version()),
+			})
+			continue
+		}
+		if upName != file.Name() {
+			problems = append(problems, ValidationError{
+				Path:    file.Path,
+				Message: fmt.Sprintf("down migration name %q does not match up migration name %q", file.Name(), upName),
+			})
+		}
+	}
+
+	sortFiles(upFiles)
+	sortFiles(downFiles)
+	return &dirContents{
+		upFiles:         upFiles,
+		downFiles:       downFiles,
+		repeatableFiles: repeatableFiles,
+		problems:        problems,
+	}, nil
+}
+
+// scanRepeatables collects the "R_<name>.sql" files among topLevel and every
+// .sql file in the repeatable folder of dir.
+func scanRepeatables(dir string, topLevel []string) ([]MigrationFile, ValidationErrors, error) {
+	nested, err := filepath.Glob(filepath.Join(dir, RepeatableDir, "*.sql"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list repeatable migration files: %w", err)
+	}
+	sort.Strings(nested)
+
+	var paths []string
+	for _, path := range topLevel {
+		if strings.HasPrefix(filepath.Base(path), "R_") {
+			paths = append(paths, path)
+		}
+	}
+	paths = append(paths, nested...)
+
+	var (
+		files    []MigrationFile
+		problems ValidationErrors
+	)
+	seen := map[string]string{}
+	for _, path := range paths {
+		name, err := parseRepeatableFileName(filepath.Base(path))
+		if err != nil {
+			problems = append(problems, ValidationError{Path: path, Message: err.Error()})
+			continue
+		}
+		if other, ok := seen[name]; ok {
+			problems = append(problems, ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("duplicate repeatable migration %s (also defined by %s)", name, other),
+			})
+			continue
+		}
+		seen[name] = path
+		files = append(files, MigrationFile{Path: path, Kind: KindRepeatable})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	return files, problems, nil
+}
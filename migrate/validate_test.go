@@ -0,0 +1,142 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMigrationFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		version  string
+		migName  string
+		kind     string
+		wantErr  bool
+	}{
+		{
+			name:     "up file",
+			fileName: "20250830133803_create-user.up.sql",
+			version:  "20250830133803",
+			migName:  "create-user",
+			kind:     "up",
+		},
+		{
+			name:     "down file whose name contains up",
+			fileName: "20250101_add_backup_table.down.sql",
+			version:  "20250101",
+			migName:  "add_backup_table",
+			kind:     "down",
+		},
+		{
+			name:     "no underscore",
+			fileName: "20250101.up.sql",
+			wantErr:  true,
+		},
+		{
+			name:     "non numeric version",
+			fileName: "v1_init.up.sql",
+			wantErr:  true,
+		},
+		{
+			name:     "missing kind",
+			fileName: "20250101_init.sql",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, name, kind, err := ParseMigrationFileName(tt.fileName)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.version, version)
+			assert.Equal(t, tt.migName, name)
+			assert.Equal(t, tt.kind, kind)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"20250101_init.up.sql",
+		"20250101_init.down.sql",
+		"20250102_add_backup_table.up.sql",
+		"20250102_add_backup_table.down.sql",
+		"20250103_irreversible.up.sql",
+		"20250104_orphan.down.sql",
+		"20250105_first.up.sql",
+		"20250105_second.up.sql",
+		"20250106_renamed.up.sql",
+		"20250106_other.down.sql",
+		"20250107.up.sql",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	problems, err := Validate(dir)
+	assert.NoError(t, err)
+
+	paths := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		paths = append(paths, filepath.Base(problem.Path))
+	}
+	assert.ElementsMatch(t, []string{
+		"20250104_orphan.down.sql",
+		"20250105_second.up.sql",
+		"20250106_other.down.sql",
+		"20250107.up.sql",
+	}, paths)
+
+	problems, err = Validate("../db/migrations")
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestMigration_Load_Repeatables(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, RepeatableDir), 0o755); err != nil {
+		t.Fatalf("failed to create repeatable dir: %v", err)
+	}
+	for _, name := range []string{
+		"20250101_init.up.sql",
+		"R_refresh_views.sql",
+		filepath.Join(RepeatableDir, "functions.sql"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	m := &Migration{
+		statusGetter: &mockStatusGetter{},
+		config:       &config.Config{},
+	}
+	if err := m.Load(dir); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	assert.Equal(t, []string{"20250101"}, m.Versions())
+	if assert.Len(t, m.RepeatableFiles, 2) {
+		assert.Equal(t, "functions", m.RepeatableFiles[0].Name())
+		assert.Equal(t, "refresh_views", m.RepeatableFiles[1].Name())
+		assert.True(t, m.RepeatableFiles[0].IsRepeatable())
+		assert.Equal(t, "", m.RepeatableFiles[0].Version())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, RepeatableDir, "R_functions.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatalf("failed to write duplicate: %v", err)
+	}
+	problems, err := Validate(dir)
+	assert.NoError(t, err)
+	assert.Len(t, problems, 1)
+}
@@ -0,0 +1,181 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Stages of a round trip at which Verify finds a problem.
+const (
+	VerifyUp      = "up"
+	VerifyDown    = "down"
+	VerifyReapply = "re-apply"
+)
+
+// VerifyProblem is a migration whose down file doesn't cleanly invert its up
+// file.
+type VerifyProblem struct {
+	Version string
+	File    string
+	// Stage is where the round trip went wrong: VerifyUp or VerifyReapply
+	// when the up file failed or left a different schema the second time,
+	// VerifyDown when the down file failed or didn't restore the schema.
+	Stage string
+	// Err is the error of the failing file, nil when Changes are reported.
+	Err error
+	// Changes are the differences from the expected schema to the one the
+	// stage produced.
+	Changes []SchemaChange
+}
+
+func (p VerifyProblem) String() string {
+	if p.Err != nil {
+		return fmt.Sprintf("%s (%s): %s failed: %v", p.Version, p.File, p.Stage, p.Err)
+	}
+	lines := []string{fmt.Sprintf("%s (%s): schema differs after %s:", p.Version, p.File, p.Stage)}
+	for _, change := range p.Changes {
+		lines = append(lines, "  "+change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Verify checks the down files of the pending migrations on a scratch
+// database. The applied migrations are run first, then each pending one is
+// applied, reverted and applied again, comparing the schema after each step.
+// Irreversible migrations are applied without a round trip. Verification
+// stops at a migration that can't be applied, since the following ones
+// depend on it.
+func (m *Migration) Verify() ([]VerifyProblem, error) {
+	currentVersion, err := m.statusGetter.GetCurrentVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current version: %w", err)
+	}
+
+	var applied, pending []MigrationFile
+	for _, file := range m.UpFiles {
+		if file.Version() <= currentVersion {
+			applied = append(applied, file)
+		} else {
+			pending = append(pending, file)
+		}
+	}
+
+	var problems []VerifyProblem
+	err = m.withScratchDatabase(func(scratch *Migration) error {
+		// Repeatable migrations are re-applied by every up and have no down.
+		scratch.RepeatableFiles = nil
+		scratch.UpFiles = applied
+		if err := scratch.Up(); err != nil {
+			return fmt.Errorf("failed to apply the applied migrations to the scratch database: %w", err)
+		}
+		// FindDownFile looks up the up file of a pending migration too.
+		scratch.UpFiles = m.UpFiles
+
+		problems, err = m.verifyPending(scratch, pending)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// verifyPending round-trips each of pending on the scratch database in turn,
+// stopping at one that can't be applied.
+func (m *Migration) verifyPending(scratch *Migration, pending []MigrationFile) ([]VerifyProblem, error) {
+	var problems []VerifyProblem
+	for _, file := range pending {
+		found, err := m.roundTrip(scratch, file)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			m.logf("Verified %s", file.Path)
+			continue
+		}
+		problems = append(problems, found...)
+		if last := found[len(found)-1]; last.Err != nil && last.Stage != VerifyDown {
+			m.logf("Stopping at %s, the following migrations depend on it", file.Path)
+			break
+		}
+	}
+	return problems, nil
+}
+
+// roundTrip applies file to the scratch database, reverts it and applies it
+// again. file stays applied unless the last problem returned is a failure at
+// VerifyUp or VerifyReapply.
+func (m *Migration) roundTrip(scratch *Migration, file MigrationFile) ([]VerifyProblem, error) {
+	problem := func(stage string, err error, changes []SchemaChange) VerifyProblem {
+		return VerifyProblem{Version: file.Version(), File: file.Path, Stage: stage, Err: err, Changes: changes}
+	}
+
+	before, err := scratch.inspectScratch()
+	if err != nil {
+		return nil, err
+	}
+	if err := scratch.applyAlone(file); err != nil {
+		return []VerifyProblem{problem(VerifyUp, err, nil)}, nil
+	}
+	after, err := scratch.inspectScratch()
+	if err != nil {
+		return nil, err
+	}
+
+	down, err := scratch.FindDownFile(file.Version())
+	var irreversible *IrreversibleMigrationError
+	if errors.As(err, &irreversible) {
+		m.logf("Skipping the down file of %s: %v", file.Path, err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []VerifyProblem
+	if err := scratch.rollback([]string{down.Version()}); err != nil {
+		// The failed down file was rolled back, so file is still applied.
+		return []VerifyProblem{problem(VerifyDown, err, nil)}, nil
+	}
+	reverted, err := scratch.inspectScratch()
+	if err != nil {
+		return nil, err
+	}
+	if changes := DiffSchemas(before, reverted); len(changes) > 0 {
+		problems = append(problems, problem(VerifyDown, nil, changes))
+	}
+
+	if err := scratch.applyAlone(file); err != nil {
+		return append(problems, problem(VerifyReapply, err, nil)), nil
+	}
+	reapplied, err := scratch.inspectScratch()
+	if err != nil {
+		return nil, err
+	}
+	if changes := DiffSchemas(after, reapplied); len(changes) > 0 {
+		problems = append(problems, problem(VerifyReapply, nil, changes))
+	}
+	return problems, nil
+}
+
+// applyAlone applies file in a transaction of its own, so that a failing
+// file leaves the database as it was.
+func (m *Migration) applyAlone(file MigrationFile) error {
+	batch, err := m.nextBatch()
+	if err != nil {
+		return err
+	}
+	return m.inTransaction(func(tx *sql.Tx) error {
+		return m.applyUp(tx, file, batch)
+	})
+}
+
+func (m *Migration) inspectScratch() (*Schema, error) {
+	schema, err := m.inspector.InspectSchema(m.context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect scratch database: %w", err)
+	}
+	return schema, nil
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gooolib/migration/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyProblem_String(t *testing.T) {
+	failed := VerifyProblem{Version: "20250102", File: "db/migrations/20250102_email.up.sql", Stage: VerifyDown,
+		Err: errors.New(`column "email" does not exist`)}
+	assert.Equal(t, `20250102 (db/migrations/20250102_email.up.sql): down failed: column "email" does not exist`, failed.String())
+
+	leftover := VerifyProblem{Version: "20250103", File: "db/migrations/20250103_orders.up.sql", Stage: VerifyDown,
+		Changes: []SchemaChange{{Change: ChangeExtra, Kind: "index", Table: "public.orders", Name: "orders_user_idx",
+			Actual: "CREATE INDEX orders_user_idx ON public.orders USING btree (user_id);"}}}
+	assert.Equal(t, "20250103 (db/migrations/20250103_orders.up.sql): schema differs after down:\n"+
+		"  + index public.orders.orders_user_idx is not in the migrations: CREATE INDEX orders_user_idx ON public.orders USING btree (user_id);",
+		leftover.String())
+}
+
+// sequenceInspector returns its schemas in turn, one per inspection, the
+// last one once they run out.
+type sequenceInspector struct {
+	schemas []*Schema
+	calls   int
+}
+
+func (i *sequenceInspector) InspectSchema(ctx context.Context) (*Schema, error) {
+	schema := i.schemas[min(i.calls, len(i.schemas)-1)]
+	i.calls++
+	return schema, nil
+}
+
+func newVerifyMigration(t *testing.T) (*Migration, *recordingDB) {
+	dir := t.TempDir()
+	m, db := newRecordingMigration(config.CmdConfig{MigrationDir: dir},
+		writeMigrationFile(t, dir, "0001_email.up.sql", "ALTER TABLE users ADD email TEXT;\n"),
+		writeMigrationFile(t, dir, "0002_name.up.sql", "ALTER TABLE users ADD name TEXT;\n"),
+		writeMigrationFile(t, dir, "0003_backfill.up.sql", "UPDATE users SET name = email;\n"))
+	m.DownFiles = []MigrationFile{
+		writeMigrationFile(t, dir, "0001_email.down.sql", "ALTER TABLE users DROP email;\n"),
+		writeMigrationFile(t, dir, "0002_name.down.sql", "SELECT 1;\n"),
+	}
+	return m, db
+}
+
+func TestMigration_RoundTrip(t *testing.T) {
+	users := &Schema{Tables: []Table{{Name: "public.users", Columns: []Column{{Name: "id", Type: "integer"}}}}}
+	email := &Schema{Tables: []Table{{Name: "public.users", Columns: []Column{{Name: "id", Type: "integer"}, {Name: "email", Type: "text"}}}}}
+	m, db := newVerifyMigration(t)
+
+	m.inspector = &sequenceInspector{schemas: []*Schema{users, email, users, email}}
+	problems, err := m.roundTrip(m, m.UpFiles[0])
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, []string{
+		"BEGIN", "ALTER TABLE users ADD email TEXT", "record 0001", "COMMIT",
+		"BEGIN", "ALTER TABLE users DROP email", "remove 0001", "COMMIT",
+		"BEGIN", "ALTER TABLE users ADD email TEXT", "record 0001", "COMMIT",
+	}, db.entries())
+
+	// The down file leaves the name column behind, and applying the up file
+	// again gives a different schema.
+	named := &Schema{Tables: []Table{{Name: "public.users", Columns: []Column{{Name: "id", Type: "integer"}, {Name: "email", Type: "text"}, {Name: "name", Type: "text"}}}}}
+	drifted := &Schema{Tables: []Table{{Name: "public.users", Columns: []Column{{Name: "id", Type: "integer"}, {Name: "email", Type: "text"}, {Name: "name", Type: "text", NotNull: true}}}}}
+	m.inspector = &sequenceInspector{schemas: []*Schema{email, named, named, drifted}}
+	problems, err = m.roundTrip(m, m.UpFiles[1])
+	assert.NoError(t, err)
+	assert.Equal(t, []VerifyProblem{
+		{Version: "0002", File: m.UpFiles[1].Path, Stage: VerifyDown, Changes: DiffSchemas(email, named)},
+		{Version: "0002", File: m.UpFiles[1].Path, Stage: VerifyReapply, Changes: DiffSchemas(named, drifted)},
+	}, problems)
+	assert.Len(t, problems[0].Changes, 1)
+	assert.Len(t, problems[1].Changes, 1)
+}
+
+func TestMigration_RoundTripIrreversible(t *testing.T) {
+	m, db := newVerifyMigration(t)
+	inspector := &sequenceInspector{schemas: []*Schema{{}}}
+	m.inspector = inspector
+
+	problems, err := m.roundTrip(m, m.UpFiles[2])
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, []string{"BEGIN", "UPDATE users SET name = email", "record 0003", "COMMIT"}, db.entries())
+	assert.Equal(t, 2, inspector.calls)
+}
+
+func TestMigration_VerifyPendingStopsAtFailedUp(t *testing.T) {
+	m, db := newVerifyMigration(t)
+	m.inspector = &sequenceInspector{schemas: []*Schema{{}}}
+	failure := errors.New(`column "email" of relation "users" already exists`)
+	db.fail = func(query string) error {
+		if strings.HasPrefix(query, "ALTER TABLE users ADD email") {
+			return failure
+		}
+		return nil
+	}
+
+	problems, err := m.verifyPending(m, m.UpFiles)
+	assert.NoError(t, err)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, VerifyUp, problems[0].Stage)
+		assert.ErrorIs(t, problems[0].Err, failure)
+	}
+	// The failed migration was rolled back and the following ones never ran.
+	assert.Equal(t, []string{"BEGIN", "ALTER TABLE users ADD email TEXT", "ROLLBACK"}, db.entries())
+}